@@ -36,6 +36,10 @@ const (
 	PermBackup = "backup"
 	// PermLoad means user can load a SQLite dump into a node.
 	PermLoad = "load"
+	// PermRotateCerts means user can rotate the certificates used between nodes.
+	PermRotateCerts = "rotate-certs"
+	// PermResync means user can rebuild the state of a node from the Leader.
+	PermResync = "resync"
 )
 
 // BasicAuther is the interface an object must support to return basic auth information.
@@ -35,6 +35,11 @@ func Unmarshal(data []byte) (*Config, *aws.S3Config, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	if s3cfg.ObjectLock != nil {
+		if err := s3cfg.ObjectLock.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
 	return cfg, s3cfg, nil
 }
 
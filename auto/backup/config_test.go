@@ -180,6 +180,69 @@ func TestUnmarshal(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		{
+			name: "ValidS3ConfigObjectLock",
+			input: []byte(`
+			{
+				"version": 1,
+				"type": "s3",
+				"interval": "24h",
+				"sub": {
+					"access_key_id": "test_id",
+					"secret_access_key": "test_secret",
+					"region": "us-west-2",
+					"bucket": "test_bucket",
+					"path": "test/path",
+					"object_lock": {
+						"mode": "compliance",
+						"retention_period": "720h",
+						"legal_hold": true
+					}
+				}
+			}
+			`),
+			expectedCfg: &Config{
+				Version:  1,
+				Type:     "s3",
+				Interval: 24 * auto.Duration(time.Hour),
+			},
+			expectedS3: &aws.S3Config{
+				AccessKeyID:     "test_id",
+				SecretAccessKey: "test_secret",
+				Region:          "us-west-2",
+				Bucket:          "test_bucket",
+				Path:            "test/path",
+				ObjectLock: &aws.S3ObjectLockConfig{
+					Mode:            "compliance",
+					RetentionPeriod: 720 * auto.Duration(time.Hour),
+					LegalHold:       true,
+				},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "InvalidS3ConfigObjectLock",
+			input: []byte(`
+			{
+				"version": 1,
+				"type": "s3",
+				"interval": "24h",
+				"sub": {
+					"access_key_id": "test_id",
+					"secret_access_key": "test_secret",
+					"region": "us-west-2",
+					"bucket": "test_bucket",
+					"path": "test/path",
+					"object_lock": {
+						"mode": "compliance"
+					}
+				}
+			}
+			`),
+			expectedCfg: nil,
+			expectedS3:  nil,
+			expectedErr: aws.ErrInvalidObjectLockRetention,
+		},
 		{
 			name: "InvalidVersion",
 			input: []byte(`
@@ -3,11 +3,14 @@ package backup
 import (
 	"compress/gzip"
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"expvar"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -24,6 +27,23 @@ type DataProvider interface {
 	Provide(path string) error
 }
 
+// IndexProvider is an optional interface a DataProvider may implement. If it
+// does, the Uploader records the index returned, which should identify the
+// version of the data about to be provided, with each upload. If the index has
+// not changed since the last upload, the data is not provided again. The index
+// must never be ahead of the data provided, and 0 means it is not known.
+type IndexProvider interface {
+	AppliedIndex() uint64
+}
+
+// DestinationProvider is an optional interface a StorageClient may implement. If
+// it does, the destination returned is used in place of String() to detect that
+// the destination has changed since the last upload, so it should identify the
+// destination completely.
+type DestinationProvider interface {
+	Destination() string
+}
+
 // stats captures stats for the Uploader service.
 var stats *expvar.Map
 
@@ -64,7 +84,9 @@ type Uploader struct {
 	lastUploadTime     time.Time
 	lastUploadDuration time.Duration
 
-	lastSum SHA256Sum
+	lastSum   SHA256Sum
+	lastIndex uint64
+	statePath string // Path to persisted state, may be empty.
 
 	// disableSumCheck is used for testing purposes to disable the check that
 	// prevents uploading the same data twice.
@@ -82,6 +104,52 @@ func NewUploader(storageClient StorageClient, dataProvider DataProvider, interva
 	}
 }
 
+// uploaderState is the state of the Uploader persisted across restarts.
+type uploaderState struct {
+	Sum         string `json:"sum"`
+	Index       uint64 `json:"index,omitempty"`
+	Destination string `json:"destination"`
+	Compress    bool   `json:"compress"`
+}
+
+// SetStatePath sets the path to a file in which the Uploader records the
+// SHA256 sum, and index if known, of the last data uploaded. If the file exists
+// its contents are loaded, so that a restart does not cause the same data to be
+// uploaded again. State recorded for a different destination, or compression
+// setting, is ignored. It must be called before Start.
+func (u *Uploader) SetStatePath(path string) error {
+	u.statePath = path
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	// The state only prevents redundant uploads, so a state file which cannot
+	// be parsed is ignored.
+	var st uploaderState
+	if err := json.Unmarshal(b, &st); err != nil {
+		u.logger.Printf("ignoring invalid upload state at %s: %s", path, err.Error())
+		return nil
+	}
+	sum, err := hex.DecodeString(st.Sum)
+	if err != nil {
+		u.logger.Printf("ignoring invalid upload state at %s: %s", path, err.Error())
+		return nil
+	}
+	if st.Destination != u.destination() || st.Compress != u.compress {
+		u.logger.Printf("ignoring upload state at %s, recorded for upload to %s with compress %t",
+			path, st.Destination, st.Compress)
+		return nil
+	}
+	u.lastSum = sum
+	u.lastIndex = st.Index
+	u.logger.Printf("loaded upload state from %s, last upload sum %s, index %d", path, u.lastSum, u.lastIndex)
+	return nil
+}
+
 // Start starts the Uploader service.
 func (u *Uploader) Start(ctx context.Context, isUploadEnabled func() bool) {
 	if isUploadEnabled == nil {
@@ -102,7 +170,7 @@ func (u *Uploader) Start(ctx context.Context, isUploadEnabled func() bool) {
 				// Reset the lastSum so that the next time we're enabled upload will
 				// happen. We do this to be conservative, as we don't know what was
 				// happening while upload was disabled.
-				u.lastSum = nil
+				u.resetState()
 				continue
 			}
 			if err := u.upload(ctx); err != nil {
@@ -112,6 +180,12 @@ func (u *Uploader) Start(ctx context.Context, isUploadEnabled func() bool) {
 	}
 }
 
+// Upload performs a single upload, independent of the periodic schedule
+// run by Start.
+func (u *Uploader) Upload(ctx context.Context) error {
+	return u.upload(ctx)
+}
+
 // Stats returns the stats for the Uploader service.
 func (u *Uploader) Stats() (map[string]interface{}, error) {
 	status := map[string]interface{}{
@@ -121,11 +195,23 @@ func (u *Uploader) Stats() (map[string]interface{}, error) {
 		"last_upload_time":     u.lastUploadTime.Format(time.RFC3339),
 		"last_upload_duration": u.lastUploadDuration.String(),
 		"last_upload_sum":      u.lastSum.String(),
+		"last_upload_index":    u.lastIndex,
 	}
 	return status, nil
 }
 
 func (u *Uploader) upload(ctx context.Context) error {
+	// If the data has not changed since the last upload there is no need to
+	// provide it, which may be expensive.
+	var index uint64
+	if ip, ok := u.dataProvider.(IndexProvider); ok {
+		index = ip.AppliedIndex()
+		if !u.disableSumCheck && index != 0 && index == u.lastIndex {
+			stats.Add(numUploadsSkipped, 1)
+			return nil
+		}
+	}
+
 	// create a temporary file for the data to be uploaded
 	filetoUpload, err := tempFilename()
 	if err != nil {
@@ -145,6 +231,13 @@ func (u *Uploader) upload(ctx context.Context) error {
 		return err
 	}
 	if !u.disableSumCheck && sum.Equals(u.lastSum) {
+		// The data uploaded last is also the data at this index.
+		if index != u.lastIndex {
+			u.lastIndex = index
+			if err := u.saveState(); err != nil {
+				u.logger.Printf("failed to save upload state to %s: %s", u.statePath, err.Error())
+			}
+		}
 		stats.Add(numUploadsSkipped, 1)
 		return nil
 	}
@@ -162,6 +255,10 @@ func (u *Uploader) upload(ctx context.Context) error {
 		stats.Add(numUploadsFail, 1)
 	} else {
 		u.lastSum = sum
+		u.lastIndex = index
+		if err := u.saveState(); err != nil {
+			u.logger.Printf("failed to save upload state to %s: %s", u.statePath, err.Error())
+		}
 		stats.Add(numUploadsOK, 1)
 		stats.Add(totalUploadBytes, cr.count)
 		stats.Get(lastUploadBytes).(*expvar.Int).Set(cr.count)
@@ -171,6 +268,49 @@ func (u *Uploader) upload(ctx context.Context) error {
 	return err
 }
 
+// saveState writes the state of the Uploader to the state file, if set. The
+// state file is replaced atomically, so it is never partially written.
+func (u *Uploader) saveState() error {
+	if u.statePath == "" {
+		return nil
+	}
+	b, err := json.Marshal(uploaderState{
+		Sum:         u.lastSum.String(),
+		Index:       u.lastIndex,
+		Destination: u.destination(),
+		Compress:    u.compress,
+	})
+	if err != nil {
+		return err
+	}
+	tmpPath := filepath.Join(filepath.Dir(u.statePath), "."+filepath.Base(u.statePath)+".tmp")
+	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, u.statePath)
+}
+
+// destination returns a description of where data is uploaded.
+func (u *Uploader) destination() string {
+	if dp, ok := u.storageClient.(DestinationProvider); ok {
+		return dp.Destination()
+	}
+	return u.storageClient.String()
+}
+
+// resetState forgets the last data uploaded, so the next upload always takes
+// place.
+func (u *Uploader) resetState() {
+	u.lastSum = nil
+	u.lastIndex = 0
+	if u.statePath == "" {
+		return
+	}
+	if err := os.Remove(u.statePath); err != nil && !os.IsNotExist(err) {
+		u.logger.Printf("failed to remove upload state at %s: %s", u.statePath, err.Error())
+	}
+}
+
 func (u *Uploader) compressIfNeeded(path string) error {
 	if !u.compress {
 		return nil
@@ -3,10 +3,12 @@ package backup
 import (
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"expvar"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -281,8 +283,156 @@ func Test_UploaderStats(t *testing.T) {
 	}
 }
 
+func Test_UploaderPersistState(t *testing.T) {
+	ResetStats()
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	uploadCount := 0
+	sc := &mockStorageClient{
+		uploadFn: func(ctx context.Context, reader io.Reader) error {
+			uploadCount++
+			return nil
+		},
+	}
+	dp := &mockIndexedDataProvider{
+		mockDataProvider: mockDataProvider{data: "my upload data"},
+		index:            5,
+	}
+	uploader := NewUploader(sc, dp, time.Hour, UploadNoCompress)
+	if err := uploader.SetStatePath(statePath); err != nil {
+		t.Fatalf("failed to set state path: %s", err.Error())
+	}
+	if err := uploader.Upload(context.Background()); err != nil {
+		t.Fatalf("failed to upload: %s", err.Error())
+	}
+	if uploadCount != 1 {
+		t.Fatalf("expected 1 upload, got %d", uploadCount)
+	}
+
+	// A new Uploader, using the same state, must not upload the same data again.
+	uploader = NewUploader(sc, dp, time.Hour, UploadNoCompress)
+	if err := uploader.SetStatePath(statePath); err != nil {
+		t.Fatalf("failed to set state path: %s", err.Error())
+	}
+	st, err := uploader.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %s", err.Error())
+	}
+	if exp, got := uint64(5), st["last_upload_index"]; exp != got {
+		t.Fatalf("expected last_upload_index of %d, got %v", exp, got)
+	}
+	if err := uploader.Upload(context.Background()); err != nil {
+		t.Fatalf("failed to upload: %s", err.Error())
+	}
+	if uploadCount != 1 {
+		t.Fatalf("expected 1 upload, got %d", uploadCount)
+	}
+	if exp, got := int64(1), stats.Get(numUploadsSkipped).(*expvar.Int).Value(); exp != got {
+		t.Fatalf("expected numUploadsSkipped to be %d, got %d", exp, got)
+	}
+	if dp.numProvides != 1 {
+		t.Fatalf("expected data to be provided once, got %d", dp.numProvides)
+	}
+
+	// Unchanged data at a new index must not be uploaded.
+	dp.index = 6
+	if err := uploader.Upload(context.Background()); err != nil {
+		t.Fatalf("failed to upload: %s", err.Error())
+	}
+	if uploadCount != 1 {
+		t.Fatalf("expected 1 upload, got %d", uploadCount)
+	}
+	if dp.numProvides != 2 {
+		t.Fatalf("expected data to be provided twice, got %d", dp.numProvides)
+	}
+
+	// Changed data must be uploaded, and the state updated.
+	dp.data = "my new upload data"
+	dp.index = 7
+	if err := uploader.Upload(context.Background()); err != nil {
+		t.Fatalf("failed to upload: %s", err.Error())
+	}
+	if uploadCount != 2 {
+		t.Fatalf("expected 2 uploads, got %d", uploadCount)
+	}
+	b, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("failed to read state file: %s", err.Error())
+	}
+	var state uploaderState
+	if err := json.Unmarshal(b, &state); err != nil {
+		t.Fatalf("failed to unmarshal state file: %s", err.Error())
+	}
+	if state.Index != 7 {
+		t.Fatalf("expected state index of 7, got %d", state.Index)
+	}
+}
+
+func Test_UploaderPersistStateChangedConfig(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	uploadCount := 0
+	uploadFn := func(ctx context.Context, reader io.Reader) error {
+		uploadCount++
+		return nil
+	}
+	dp := &mockIndexedDataProvider{
+		mockDataProvider: mockDataProvider{data: "my upload data"},
+		index:            5,
+	}
+	mustUpload := func(sc StorageClient, compress bool, expCount int) {
+		t.Helper()
+		uploader := NewUploader(sc, dp, time.Hour, compress)
+		if err := uploader.SetStatePath(statePath); err != nil {
+			t.Fatalf("failed to set state path: %s", err.Error())
+		}
+		if err := uploader.Upload(context.Background()); err != nil {
+			t.Fatalf("failed to upload: %s", err.Error())
+		}
+		if uploadCount != expCount {
+			t.Fatalf("expected %d uploads, got %d", expCount, uploadCount)
+		}
+	}
+
+	mustUpload(&mockStorageClient{uploadFn: uploadFn, dest: "s3://bucket/key1"}, UploadNoCompress, 1)
+	mustUpload(&mockStorageClient{uploadFn: uploadFn, dest: "s3://bucket/key1"}, UploadNoCompress, 1)
+
+	// The same data must be uploaded to a new destination, and uploaded again
+	// if the compression setting changes.
+	mustUpload(&mockStorageClient{uploadFn: uploadFn, dest: "s3://bucket/key2"}, UploadNoCompress, 2)
+	mustUpload(&mockStorageClient{uploadFn: uploadFn, dest: "s3://bucket/key2"}, UploadCompress, 3)
+	mustUpload(&mockStorageClient{uploadFn: uploadFn, dest: "s3://bucket/key2"}, UploadCompress, 3)
+}
+
+func Test_UploaderPersistStateInvalid(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(statePath, []byte("not json"), 0644); err != nil {
+		t.Fatalf("failed to write state file: %s", err.Error())
+	}
+
+	uploadCount := 0
+	sc := &mockStorageClient{
+		uploadFn: func(ctx context.Context, reader io.Reader) error {
+			uploadCount++
+			return nil
+		},
+	}
+	dp := &mockDataProvider{data: "my upload data"}
+	uploader := NewUploader(sc, dp, time.Hour, UploadNoCompress)
+	if err := uploader.SetStatePath(statePath); err != nil {
+		t.Fatalf("invalid state file returned error: %s", err.Error())
+	}
+	if err := uploader.Upload(context.Background()); err != nil {
+		t.Fatalf("failed to upload: %s", err.Error())
+	}
+	if uploadCount != 1 {
+		t.Fatalf("expected 1 upload, got %d", uploadCount)
+	}
+}
+
 type mockStorageClient struct {
 	uploadFn func(ctx context.Context, reader io.Reader) error
+	dest     string
 }
 
 func (mc *mockStorageClient) Upload(ctx context.Context, reader io.Reader) error {
@@ -296,14 +446,32 @@ func (mc *mockStorageClient) String() string {
 	return "mockStorageClient"
 }
 
+func (mc *mockStorageClient) Destination() string {
+	if mc.dest != "" {
+		return mc.dest
+	}
+	return mc.String()
+}
+
 type mockDataProvider struct {
-	data string
-	err  error
+	data        string
+	err         error
+	numProvides int
 }
 
 func (mp *mockDataProvider) Provide(path string) error {
+	mp.numProvides++
 	if mp.err != nil {
 		return mp.err
 	}
 	return os.WriteFile(path, []byte(mp.data), 0644)
 }
+
+type mockIndexedDataProvider struct {
+	mockDataProvider
+	index uint64
+}
+
+func (mp *mockIndexedDataProvider) AppliedIndex() uint64 {
+	return mp.index
+}
@@ -2,16 +2,39 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/rqlite/rqlite/auto"
 )
 
+var (
+	// ErrInvalidObjectLockMode is returned when the Object Lock mode is not supported.
+	ErrInvalidObjectLockMode = errors.New("invalid object lock mode")
+
+	// ErrInvalidObjectLockRetention is returned when the Object Lock retention is not
+	// set, or is set more than one way.
+	ErrInvalidObjectLockRetention = errors.New("object lock requires exactly one of retain_until or retention_period")
+
+	// ErrObjectLockRetainUntilPassed is returned when the Object Lock retain-until
+	// date is not in the future. S3 rejects any upload with such a date.
+	ErrObjectLockRetainUntilPassed = errors.New("object lock retain_until date has passed")
+)
+
+// retainUntilWarnPeriod is how long before a fixed Object Lock retain-until date
+// warnings are logged on upload.
+const retainUntilWarnPeriod = 7 * 24 * time.Hour
+
 // S3Config is the subconfig for the S3 storage type
 type S3Config struct {
 	Endpoint        string `json:"endpoint,omitempty"`
@@ -20,6 +43,59 @@ type S3Config struct {
 	SecretAccessKey string `json:"secret_access_key"`
 	Bucket          string `json:"bucket"`
 	Path            string `json:"path"`
+
+	ObjectLock *S3ObjectLockConfig `json:"object_lock,omitempty"`
+}
+
+// S3ObjectLockConfig is the subconfig for S3 Object Lock settings. If set, every
+// object uploaded is placed under retention, and cannot be overwritten or deleted
+// until the retention expires. The bucket must have Object Lock enabled.
+type S3ObjectLockConfig struct {
+	// Mode is the retention mode, either "governance" or "compliance".
+	Mode string `json:"mode"`
+
+	// RetainUntil is a fixed date until which uploaded objects are retained.
+	RetainUntil time.Time `json:"retain_until,omitempty"`
+
+	// RetentionPeriod is the period, measured from the time of upload, for
+	// which uploaded objects are retained.
+	RetentionPeriod auto.Duration `json:"retention_period,omitempty"`
+
+	// LegalHold places a legal hold on uploaded objects.
+	LegalHold bool `json:"legal_hold,omitempty"`
+}
+
+// Validate checks that the Object Lock config is valid. A retain-until date
+// which has passed is not checked here, so that a node can still start with
+// such a config. Uploads fail instead.
+func (o *S3ObjectLockConfig) Validate() error {
+	if _, err := o.mode(); err != nil {
+		return err
+	}
+	if o.RetentionPeriod < 0 || o.RetainUntil.IsZero() == (o.RetentionPeriod == 0) {
+		return ErrInvalidObjectLockRetention
+	}
+	return nil
+}
+
+// RetainUntilDate returns the date until which an object uploaded at time
+// now should be retained.
+func (o *S3ObjectLockConfig) RetainUntilDate(now time.Time) time.Time {
+	if !o.RetainUntil.IsZero() {
+		return o.RetainUntil
+	}
+	return now.Add(time.Duration(o.RetentionPeriod))
+}
+
+func (o *S3ObjectLockConfig) mode() (string, error) {
+	switch strings.ToLower(o.Mode) {
+	case "governance":
+		return s3.ObjectLockModeGovernance, nil
+	case "compliance":
+		return s3.ObjectLockModeCompliance, nil
+	default:
+		return "", ErrInvalidObjectLockMode
+	}
 }
 
 // S3Client is a client for uploading data to S3.
@@ -31,6 +107,9 @@ type S3Client struct {
 	bucket    string
 	key       string
 
+	objectLock *S3ObjectLockConfig
+	logger     *log.Logger
+
 	// These fields are used for testing via dependency injection.
 	uploader   uploader
 	downloader downloader
@@ -45,6 +124,7 @@ func NewS3Client(endpoint, region, accessKey, secretKey, bucket, key string) *S3
 		secretKey: secretKey,
 		bucket:    bucket,
 		key:       key,
+		logger:    log.New(os.Stderr, "[s3] ", log.LstdFlags),
 	}
 }
 
@@ -53,6 +133,27 @@ func (s *S3Client) String() string {
 	return fmt.Sprintf("s3://%s/%s", s.bucket, s.key)
 }
 
+// Destination returns a description of where the S3Client uploads data. Unlike
+// String, it includes any custom endpoint.
+func (s *S3Client) Destination() string {
+	if s.endpoint == "" {
+		return s.String()
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.endpoint, "/"), s.bucket, s.key)
+}
+
+// SetObjectLock sets the Object Lock settings applied to all subsequent
+// uploads. Passing nil disables Object Lock.
+func (s *S3Client) SetObjectLock(cfg *S3ObjectLockConfig) error {
+	if cfg != nil {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	s.objectLock = cfg
+	return nil
+}
+
 // Upload uploads data to S3.
 func (s *S3Client) Upload(ctx context.Context, reader io.Reader) error {
 	sess, err := s.createSession()
@@ -68,11 +169,16 @@ func (s *S3Client) Upload(ctx context.Context, reader io.Reader) error {
 		uploader = s.uploader
 	}
 
-	_, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+	input := &s3manager.UploadInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(s.key),
 		Body:   reader,
-	})
+	}
+	if err := s.applyObjectLock(input, time.Now()); err != nil {
+		return err
+	}
+
+	_, err = uploader.UploadWithContext(ctx, input)
 	if err != nil {
 		return fmt.Errorf("failed to upload to %v: %w", s, err)
 	}
@@ -106,6 +212,34 @@ func (s *S3Client) Download(ctx context.Context, writer io.WriterAt) error {
 	return nil
 }
 
+// applyObjectLock sets any Object Lock fields on the upload input. S3 requires
+// an integrity check on uploads with Object Lock settings, so a checksum is
+// also requested.
+func (s *S3Client) applyObjectLock(input *s3manager.UploadInput, now time.Time) error {
+	if s.objectLock == nil {
+		return nil
+	}
+	mode, err := s.objectLock.mode()
+	if err != nil {
+		return err
+	}
+	until := s.objectLock.RetainUntilDate(now)
+	if !until.After(now) {
+		return ErrObjectLockRetainUntilPassed
+	}
+	if !s.objectLock.RetainUntil.IsZero() && until.Sub(now) < retainUntilWarnPeriod && s.logger != nil {
+		s.logger.Printf("object lock retain_until date %s is in less than %s, uploads to %s will fail after it",
+			until.Format(time.RFC3339), retainUntilWarnPeriod, s)
+	}
+	input.ObjectLockMode = aws.String(mode)
+	input.ObjectLockRetainUntilDate = aws.Time(until)
+	if s.objectLock.LegalHold {
+		input.ObjectLockLegalHoldStatus = aws.String(s3.ObjectLockLegalHoldStatusOn)
+	}
+	input.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmSha256)
+	return nil
+}
+
 func (s *S3Client) createSession() (*session.Session, error) {
 	sess, err := session.NewSession(&aws.Config{
 		Endpoint:    aws.String(s.endpoint),
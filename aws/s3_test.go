@@ -7,10 +7,12 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/rqlite/rqlite/auto"
 )
 
 func Test_NewS3Client(t *testing.T) {
@@ -39,6 +41,17 @@ func Test_S3Client_String(t *testing.T) {
 	}
 }
 
+func Test_S3Client_Destination(t *testing.T) {
+	c := NewS3Client("", "region1", "access", "secret", "bucket2", "key3")
+	if exp, got := "s3://bucket2/key3", c.Destination(); exp != got {
+		t.Fatalf("expected Destination() to be %q, got %q", exp, got)
+	}
+	c = NewS3Client("https://endpoint1/", "region1", "access", "secret", "bucket2", "key3")
+	if exp, got := "https://endpoint1/bucket2/key3", c.Destination(); exp != got {
+		t.Fatalf("expected Destination() to be %q, got %q", exp, got)
+	}
+}
+
 func TestS3ClientUploadOK(t *testing.T) {
 	endpoint := "https://my-custom-s3-endpoint.com"
 	region := "us-west-2"
@@ -120,6 +133,134 @@ func TestS3ClientUploadFail(t *testing.T) {
 	}
 }
 
+func TestS3ClientUploadObjectLock(t *testing.T) {
+	var gotInput *s3manager.UploadInput
+	mockUploader := &mockUploader{
+		uploadFn: func(ctx aws.Context, input *s3manager.UploadInput, opts ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+			gotInput = input
+			return &s3manager.UploadOutput{}, nil
+		},
+	}
+
+	client := &S3Client{
+		region:   "us-west-2",
+		bucket:   "your-bucket",
+		key:      "your/key/path",
+		uploader: mockUploader,
+	}
+	err := client.SetObjectLock(&S3ObjectLockConfig{
+		Mode:            "compliance",
+		RetentionPeriod: auto.Duration(24 * time.Hour),
+		LegalHold:       true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error setting object lock: %v", err)
+	}
+
+	before := time.Now()
+	if err := client.Upload(context.Background(), strings.NewReader("test data")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotInput.ObjectLockMode == nil || *gotInput.ObjectLockMode != s3.ObjectLockModeCompliance {
+		t.Fatalf("expected object lock mode to be %q, got %v", s3.ObjectLockModeCompliance, gotInput.ObjectLockMode)
+	}
+	if gotInput.ObjectLockRetainUntilDate == nil {
+		t.Fatalf("expected object lock retain-until date to be set")
+	}
+	if exp := before.Add(24 * time.Hour); gotInput.ObjectLockRetainUntilDate.Before(exp) {
+		t.Fatalf("expected retain-until date to be at least %v, got %v", exp, *gotInput.ObjectLockRetainUntilDate)
+	}
+	if gotInput.ObjectLockLegalHoldStatus == nil || *gotInput.ObjectLockLegalHoldStatus != s3.ObjectLockLegalHoldStatusOn {
+		t.Fatalf("expected legal hold to be on, got %v", gotInput.ObjectLockLegalHoldStatus)
+	}
+	if gotInput.ChecksumAlgorithm == nil {
+		t.Fatalf("expected checksum algorithm to be set")
+	}
+
+	// Disable object lock, and check it's no longer applied.
+	if err := client.SetObjectLock(nil); err != nil {
+		t.Fatalf("Unexpected error clearing object lock: %v", err)
+	}
+	if err := client.Upload(context.Background(), strings.NewReader("test data")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gotInput.ObjectLockMode != nil {
+		t.Fatalf("expected object lock mode to be unset, got %v", *gotInput.ObjectLockMode)
+	}
+}
+
+func TestS3ClientUploadObjectLockUntilPassed(t *testing.T) {
+	uploadCalled := false
+	mockUploader := &mockUploader{
+		uploadFn: func(ctx aws.Context, input *s3manager.UploadInput, opts ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+			uploadCalled = true
+			return &s3manager.UploadOutput{}, nil
+		},
+	}
+	client := &S3Client{
+		region:   "us-west-2",
+		bucket:   "your-bucket",
+		key:      "your/key/path",
+		uploader: mockUploader,
+	}
+	until := time.Now().Add(time.Hour)
+	if err := client.SetObjectLock(&S3ObjectLockConfig{Mode: "governance", RetainUntil: until}); err != nil {
+		t.Fatalf("Unexpected error setting object lock: %v", err)
+	}
+
+	input := &s3manager.UploadInput{}
+	if err := client.applyObjectLock(input, until.Add(time.Second)); err != ErrObjectLockRetainUntilPassed {
+		t.Fatalf("expected ErrObjectLockRetainUntilPassed, got %v", err)
+	}
+	if err := client.Upload(context.Background(), strings.NewReader("test data")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !uploadCalled {
+		t.Fatalf("expected upload to be attempted before retain-until date")
+	}
+
+	// A date which has passed is accepted by the config, but uploads fail.
+	uploadCalled = false
+	if err := client.SetObjectLock(&S3ObjectLockConfig{Mode: "governance", RetainUntil: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("Unexpected error setting object lock with passed date: %v", err)
+	}
+	if err := client.Upload(context.Background(), strings.NewReader("test data")); err != ErrObjectLockRetainUntilPassed {
+		t.Fatalf("expected ErrObjectLockRetainUntilPassed, got %v", err)
+	}
+	if uploadCalled {
+		t.Fatalf("expected upload not to be attempted after retain-until date")
+	}
+}
+
+func Test_S3ObjectLockConfig_Validate(t *testing.T) {
+	until := time.Now().AddDate(1, 0, 0)
+	testCases := []struct {
+		name string
+		cfg  S3ObjectLockConfig
+		exp  error
+	}{
+		{"governance period", S3ObjectLockConfig{Mode: "governance", RetentionPeriod: auto.Duration(time.Hour)}, nil},
+		{"compliance until", S3ObjectLockConfig{Mode: "COMPLIANCE", RetainUntil: until}, nil},
+		{"bad mode", S3ObjectLockConfig{Mode: "forever", RetainUntil: until}, ErrInvalidObjectLockMode},
+		{"no retention", S3ObjectLockConfig{Mode: "governance"}, ErrInvalidObjectLockRetention},
+		{"both retentions", S3ObjectLockConfig{Mode: "governance", RetainUntil: until, RetentionPeriod: auto.Duration(time.Hour)}, ErrInvalidObjectLockRetention},
+		{"negative period", S3ObjectLockConfig{Mode: "governance", RetentionPeriod: auto.Duration(-time.Hour)}, ErrInvalidObjectLockRetention},
+		{"until passed", S3ObjectLockConfig{Mode: "governance", RetainUntil: time.Now().Add(-time.Hour)}, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.Validate(); err != tc.exp {
+				t.Fatalf("expected error %v, got %v", tc.exp, err)
+			}
+		})
+	}
+
+	cfg := S3ObjectLockConfig{Mode: "governance", RetainUntil: until}
+	if got := cfg.RetainUntilDate(time.Now()); !got.Equal(until) {
+		t.Fatalf("expected retain-until date %v, got %v", until, got)
+	}
+}
+
 func TestS3ClientDownloadOK(t *testing.T) {
 	region := "us-west-2"
 	accessKey := "your-access-key"
@@ -375,6 +375,45 @@ func (c *Client) Join(jr *command.JoinRequest, nodeAddr string, timeout time.Dur
 	return nil
 }
 
+// RotateCerts performs one phase of a certificate rotation on a remote node.
+func (c *Client) RotateCerts(rr *RotateCertsRequest, nodeAddr string, creds *Credentials, timeout time.Duration) error {
+	conn, err := c.dial(nodeAddr, c.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Create the request.
+	command := &Command{
+		Type: Command_COMMAND_TYPE_ROTATE_CERTS,
+		Request: &Command_RotateCertsRequest{
+			RotateCertsRequest: rr,
+		},
+		Credentials: creds,
+	}
+	if err := writeCommand(conn, command, timeout); err != nil {
+		handleConnError(conn)
+		return err
+	}
+
+	p, err := readResponse(conn, timeout)
+	if err != nil {
+		handleConnError(conn)
+		return err
+	}
+
+	a := &CommandRotateCertsResponse{}
+	err = proto.Unmarshal(p, a)
+	if err != nil {
+		return err
+	}
+
+	if a.Error != "" {
+		return errors.New(a.Error)
+	}
+	return nil
+}
+
 // Stats returns stats on the Client instance
 func (c *Client) Stats() (map[string]interface{}, error) {
 	c.mu.RLock()
@@ -34,6 +34,7 @@ const (
 	numRemoveNodeRequest  = "num_remove_node_req"
 	numNotifyRequest      = "num_notify_req"
 	numJoinRequest        = "num_join_req"
+	numRotateCertsRequest = "num_rotate_certs_req"
 	numClientRetries      = "num_client_retries"
 
 	// Client stats for this package.
@@ -62,6 +63,7 @@ func init() {
 	stats.Add(numGetNodeAPIRequestLocal, 0)
 	stats.Add(numNotifyRequest, 0)
 	stats.Add(numJoinRequest, 0)
+	stats.Add(numRotateCertsRequest, 0)
 	stats.Add(numClientRetries, 0)
 }
 
@@ -107,6 +109,17 @@ type Manager interface {
 	Join(n *command.JoinRequest) error
 }
 
+// CertManager is the interface systems managing the certificates used between
+// nodes must implement.
+type CertManager interface {
+	// StageCA adds the given CA certificates to those trusted by this node.
+	StageCA(caCert []byte) error
+
+	// ActivateCert sets the certificate presented by this node. Once overlap
+	// has elapsed only the CA certificates staged in this rotation are trusted.
+	ActivateCert(cert, key []byte, overlap time.Duration) error
+}
+
 // CredentialStore is the interface credential stores must support.
 type CredentialStore interface {
 	// AA authenticates and checks authorization for the given perm.
@@ -127,6 +140,8 @@ type Service struct {
 	db  Database // The queryable system.
 	mgr Manager  // The cluster management system.
 
+	certMgr CertManager // Manages internode certificates. May not be set.
+
 	credentialStore CredentialStore
 
 	mu      sync.RWMutex
@@ -180,6 +195,14 @@ func (s *Service) SetAPIAddr(addr string) {
 	s.apiAddr = addr
 }
 
+// SetCertManager sets the system that manages the certificates used between
+// nodes. If not set, certificate rotation requests are rejected.
+func (s *Service) SetCertManager(m CertManager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.certMgr = m
+}
+
 // GetAPIAddr returns the previously-set API address
 func (s *Service) GetAPIAddr() string {
 	s.mu.RLock()
@@ -456,10 +479,44 @@ func (s *Service) handleConn(conn net.Conn) {
 				}
 			}
 			marshalAndWrite(conn, resp)
+
+		case Command_COMMAND_TYPE_ROTATE_CERTS:
+			stats.Add(numRotateCertsRequest, 1)
+			resp := &CommandRotateCertsResponse{}
+
+			rr := c.GetRotateCertsRequest()
+			if rr == nil {
+				resp.Error = "RotateCertsRequest is nil"
+			} else if !s.checkCommandPerm(c, auth.PermRotateCerts) {
+				resp.Error = "unauthorized"
+			} else {
+				if err := s.rotateCerts(rr); err != nil {
+					resp.Error = err.Error()
+				}
+			}
+			marshalAndWrite(conn, resp)
 		}
 	}
 }
 
+func (s *Service) rotateCerts(rr *RotateCertsRequest) error {
+	s.mu.RLock()
+	m := s.certMgr
+	s.mu.RUnlock()
+	if m == nil {
+		return fmt.Errorf("certificate rotation not enabled")
+	}
+
+	switch rr.Phase {
+	case RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_STAGE:
+		return m.StageCA(rr.CaCert)
+	case RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_ACTIVATE:
+		return m.ActivateCert(rr.Cert, rr.Key, time.Duration(rr.Overlap))
+	default:
+		return fmt.Errorf("unknown certificate rotation phase %s", rr.Phase)
+	}
+}
+
 func marshalAndWrite(conn net.Conn, m proto.Message) {
 	p, err := proto.Marshal(m)
 	if err != nil {
@@ -357,6 +357,73 @@ func Test_NewServiceJoin(t *testing.T) {
 	wg.Wait()
 }
 
+func Test_NewServiceRotateCerts(t *testing.T) {
+	ml := mustNewMockTransport()
+	s := New(ml, mustNewMockDatabase(), mustNewMockManager(), mustNewMockCredentialStore())
+	if s == nil {
+		t.Fatalf("failed to create cluster service")
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("failed to open cluster service")
+	}
+	defer s.Close()
+
+	c := NewClient(ml, 30*time.Second)
+	stageReq := &RotateCertsRequest{
+		Phase:  RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_STAGE,
+		CaCert: []byte("ca"),
+	}
+
+	// Rotation should fail if no certificate manager is set.
+	if err := c.RotateCerts(stageReq, s.Addr(), nil, 5*time.Second); err == nil {
+		t.Fatalf("expected error rotating certificates without certificate manager")
+	}
+
+	cm := &mockCertManager{}
+	cm.stageCAFn = func(caCert []byte) error {
+		if string(caCert) != "ca" {
+			t.Fatalf("wrong CA certificate staged, got %s", caCert)
+		}
+		return nil
+	}
+	cm.activateCertFn = func(cert, key []byte, overlap time.Duration) error {
+		if string(cert) != "cert" || string(key) != "key" {
+			t.Fatalf("wrong certificate activated, got %s, %s", cert, key)
+		}
+		if overlap != time.Minute {
+			t.Fatalf("wrong overlap, exp %s, got %s", time.Minute, overlap)
+		}
+		return nil
+	}
+	s.SetCertManager(cm)
+
+	if err := c.RotateCerts(stageReq, s.Addr(), nil, 5*time.Second); err != nil {
+		t.Fatalf("failed to stage CA certificate: %s", err)
+	}
+	activateReq := &RotateCertsRequest{
+		Phase:   RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_ACTIVATE,
+		Cert:    []byte("cert"),
+		Key:     []byte("key"),
+		Overlap: time.Minute.Nanoseconds(),
+	}
+	if err := c.RotateCerts(activateReq, s.Addr(), nil, 5*time.Second); err != nil {
+		t.Fatalf("failed to activate certificate: %s", err)
+	}
+	if cm.numStage != 1 || cm.numActivate != 1 {
+		t.Fatalf("wrong number of calls to certificate manager, stage %d, activate %d",
+			cm.numStage, cm.numActivate)
+	}
+
+	// Errors from the certificate manager should be returned to the client.
+	cm.activateCertFn = func(cert, key []byte, overlap time.Duration) error {
+		return fmt.Errorf("bad certificate")
+	}
+	err := c.RotateCerts(activateReq, s.Addr(), nil, 5*time.Second)
+	if err == nil || err.Error() != "bad certificate" {
+		t.Fatalf("expected certificate manager error, got %v", err)
+	}
+}
+
 type mockTransport struct {
 	tn              net.Listener
 	remoteEncrypted bool
@@ -495,6 +562,29 @@ func mustNewMockManager() *MockManager {
 	return &MockManager{}
 }
 
+type mockCertManager struct {
+	stageCAFn      func(caCert []byte) error
+	activateCertFn func(cert, key []byte, overlap time.Duration) error
+	numStage       int
+	numActivate    int
+}
+
+func (m *mockCertManager) StageCA(caCert []byte) error {
+	m.numStage++
+	if m.stageCAFn == nil {
+		return nil
+	}
+	return m.stageCAFn(caCert)
+}
+
+func (m *mockCertManager) ActivateCert(cert, key []byte, overlap time.Duration) error {
+	m.numActivate++
+	if m.activateCertFn == nil {
+		return nil
+	}
+	return m.activateCertFn(cert, key, overlap)
+}
+
 func mustCreateTLSConfig() *tls.Config {
 	var err error
 
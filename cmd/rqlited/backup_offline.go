@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/rqlite/rqlite/auto/backup"
+	"github.com/rqlite/rqlite/aws"
+	"github.com/rqlite/rqlite/store"
+)
+
+const backupOfflineCmd = "backup-offline"
+
+// backupOffline implements the backup-offline subcommand. It writes a SQLite
+// copy of the database held in a stopped node's data directory to a local
+// file, and optionally uploads it using an auto-backup configuration file.
+// The backup may include log entries which were never committed, so it is
+// never uploaded over the cluster's own auto-backup, and a different
+// destination path must be given.
+func backupOffline(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet(backupOfflineCmd, flag.ExitOnError)
+	autoBackupFile := fs.String("auto-backup", "", "Path to automatic backup configuration file. If set, the backup is also uploaded")
+	destPath := fs.String("dest-path", "", "Path within the auto-backup bucket to upload to. Required with -auto-backup, and must differ from the configured path")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "\nWrite a backup of the database in the data directory of a stopped node.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] <data directory> <destination file>\n", name, backupOfflineCmd)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("data directory and destination file must be set")
+	}
+
+	dataPath, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to determine absolute data path: %s", err.Error())
+	}
+	dstPath := fs.Arg(1)
+	if _, err := os.Stat(dstPath); err == nil {
+		return fmt.Errorf("destination file %s already exists", dstPath)
+	}
+
+	if *destPath != "" && *autoBackupFile == "" {
+		return errors.New("-dest-path is set, but -auto-backup is not")
+	}
+
+	var sc *aws.S3Client
+	var uCfg *backup.Config
+	if *autoBackupFile != "" {
+		b, err := backup.ReadConfigFile(*autoBackupFile)
+		if err != nil {
+			return fmt.Errorf("failed to read auto-backup file: %s", err.Error())
+		}
+		var s3cfg *aws.S3Config
+		uCfg, s3cfg, err = backup.Unmarshal(b)
+		if err != nil {
+			return fmt.Errorf("failed to parse auto-backup file: %s", err.Error())
+		}
+		if *destPath == "" {
+			return errors.New("-dest-path must be set with -auto-backup")
+		}
+		if *destPath == s3cfg.Path {
+			return fmt.Errorf("-dest-path must differ from the auto-backup path %s", s3cfg.Path)
+		}
+		sc = aws.NewS3Client(s3cfg.Endpoint, s3cfg.Region, s3cfg.AccessKeyID, s3cfg.SecretAccessKey,
+			s3cfg.Bucket, *destPath)
+		if err := sc.SetObjectLock(s3cfg.ObjectLock); err != nil {
+			return fmt.Errorf("failed to configure auto-backup object lock: %s", err.Error())
+		}
+	}
+
+	logger := log.New(os.Stderr, "[store] ", log.LstdFlags)
+	idx, err := store.BackupOffline(dataPath, logger, dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %s", dataPath, err.Error())
+	}
+	log.Printf("backup of %s, up to log index %d, written to %s", dataPath, idx, dstPath)
+
+	if sc == nil {
+		return nil
+	}
+	u := backup.NewUploader(sc, &fileProvider{path: dstPath}, 0, !uCfg.NoCompress)
+	if err := u.Upload(ctx); err != nil {
+		return fmt.Errorf("failed to upload backup to %s: %s", sc, err.Error())
+	}
+	log.Printf("backup uploaded to %s", sc)
+	return nil
+}
+
+// fileProvider implements the uploader DataProvider interface, providing
+// a copy of an existing file.
+type fileProvider struct {
+	path string
+}
+
+// Provide copies the file to path.
+func (f *fileProvider) Provide(path string) error {
+	src, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return err
+	}
+	return dst.Close()
+}
@@ -72,6 +72,10 @@ type Config struct {
 	// HTTPVerifyClient indicates whether the HTTP server should verify client certificates.
 	HTTPVerifyClient bool
 
+	// HTTPAllowInsecureCertRotation allows node certificates to be rotated over
+	// HTTP, even though rotation requests carry private keys.
+	HTTPAllowInsecureCertRotation bool
+
 	// NodeX509CACert is the path to the CA certficate file for when this node verifies
 	// other certificates for any inter-node communications. May not be set.
 	NodeX509CACert string `filepath:"true"`
@@ -137,6 +141,20 @@ type Config struct {
 	// PprofEnabled enables Go PProf information. Defaults to true.
 	PprofEnabled bool
 
+	// HTTPAccessLog is the path to the HTTP access log file, or "stdout" or "stderr".
+	// May not be set.
+	HTTPAccessLog string
+
+	// HTTPAccessLogFormat sets the HTTP access log format.
+	HTTPAccessLogFormat string
+
+	// HTTPAccessLogSampleRate is the fraction of HTTP requests written to the access log.
+	HTTPAccessLogSampleRate float64
+
+	// HTTPAccessLogSampleRates overrides HTTPAccessLogSampleRate for requests to specific
+	// endpoints. May not be set.
+	HTTPAccessLogSampleRates string
+
 	// OnDisk enables on-disk mode.
 	OnDisk bool
 
@@ -211,6 +229,14 @@ type Config struct {
 	// WriteQueueTx controls whether writes from the queue are done within a transaction.
 	WriteQueueTx bool
 
+	// WriteCoalesceBatchSz is the maximum number of concurrent Execute requests the
+	// Leader merges into a single Raft log entry. Coalescing is disabled if less than 2.
+	WriteCoalesceBatchSz int
+
+	// WriteCoalesceTimeout is the maximum time the Leader waits for more Execute
+	// requests to merge, once it has received one.
+	WriteCoalesceTimeout time.Duration
+
 	// CompressionSize sets request query size for compression attempt
 	CompressionSize int
 
@@ -440,6 +466,7 @@ func ParseFlags(name, desc string, build *BuildInfo) (*Config, error) {
 	flag.StringVar(&config.HTTPx509Key, HTTPx509KeyFlag, "", "Path to HTTPS X.509 private key")
 	flag.BoolVar(&config.NoHTTPVerify, "http-no-verify", false, "Skip verification of remote node's HTTPS certificate when joining a cluster")
 	flag.BoolVar(&config.HTTPVerifyClient, "http-verify-client", false, "Enable mutual TLS for HTTPS")
+	flag.BoolVar(&config.HTTPAllowInsecureCertRotation, "http-allow-insecure-cert-rotation", false, "Allow node certificate rotation, which sends private keys, when HTTPS is not enabled")
 	flag.StringVar(&config.NodeX509CACert, "node-ca-cert", "", "Path to X.509 CA certificate for node-to-node encryption")
 	flag.StringVar(&config.NodeX509Cert, NodeX509CertFlag, "", "Path to X.509 certificate for node-to-node mutual authentication and encryption")
 	flag.StringVar(&config.NodeX509Key, NodeX509KeyFlag, "", "Path to X.509 private key for node-to-node mutual authentication and encryption")
@@ -462,6 +489,10 @@ func ParseFlags(name, desc string, build *BuildInfo) (*Config, error) {
 	flag.StringVar(&config.DiscoConfig, "disco-config", "", "Set discovery config, or path to cluster discovery config file")
 	flag.BoolVar(&config.Expvar, "expvar", true, "Serve expvar data on HTTP server")
 	flag.BoolVar(&config.PprofEnabled, "pprof", true, "Serve pprof data on HTTP server")
+	flag.StringVar(&config.HTTPAccessLog, "http-access-log", "", "Path to HTTP access log file, or stdout or stderr. If not set, not enabled")
+	flag.StringVar(&config.HTTPAccessLogFormat, "http-access-log-format", "common", "HTTP access log format, one of common, combined, or json")
+	flag.Float64Var(&config.HTTPAccessLogSampleRate, "http-access-log-sample-rate", 1.0, "Fraction of HTTP requests written to access log")
+	flag.StringVar(&config.HTTPAccessLogSampleRates, "http-access-log-sample-rates", "", "Comma-delimited per-endpoint access log sampling rates, e.g. /db/query=0.1,/status=0")
 	flag.BoolVar(&config.OnDisk, "on-disk", false, "Use an on-disk SQLite database")
 	flag.StringVar(&config.OnDiskPath, "on-disk-path", "", "Path for SQLite on-disk database file. If not set, use a file in data directory")
 	flag.BoolVar(&config.FKConstraints, "fk", false, "Enable SQLite foreign key constraints")
@@ -485,6 +516,8 @@ func ParseFlags(name, desc string, build *BuildInfo) (*Config, error) {
 	flag.IntVar(&config.WriteQueueBatchSz, "write-queue-batch-size", 128, "QueuedWrites queue batch size")
 	flag.DurationVar(&config.WriteQueueTimeout, "write-queue-timeout", 50*time.Millisecond, "QueuedWrites queue timeout")
 	flag.BoolVar(&config.WriteQueueTx, "write-queue-tx", false, "Use a transaction when processing a queued write")
+	flag.IntVar(&config.WriteCoalesceBatchSz, "write-coalesce-batch-size", 0, "Maximum number of concurrent writes merged into one Raft log entry. If less than 2, not enabled")
+	flag.DurationVar(&config.WriteCoalesceTimeout, "write-coalesce-timeout", 0, "Maximum time to wait for concurrent writes to merge. If 0, only merge writes already waiting")
 	flag.IntVar(&config.CompressionSize, "compression-size", 150, "Request query size for Raft log compression attempt")
 	flag.IntVar(&config.CompressionBatch, "compression-batch", 5, "Request batch threshold for Raft log compression attempt")
 	flag.StringVar(&config.CPUProfile, "cpu-profile", "", "Path to file for CPU profiling information")
@@ -492,6 +525,7 @@ func ParseFlags(name, desc string, build *BuildInfo) (*Config, error) {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "\n%s\n\n", desc)
 		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <data directory>\n", name)
+		fmt.Fprintf(os.Stderr, "       %s %s [flags] <data directory> <destination file>\n", name, backupOfflineCmd)
 		flag.PrintDefaults()
 	}
 	flag.Parse()
@@ -5,10 +5,12 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
@@ -50,6 +52,10 @@ storage engine. It provides an easy-to-use, fault-tolerant store for relational
 
 Visit https://www.rqlite.io to learn more.`
 
+// autoBackupStateFile is the file, within the data directory, in which the
+// auto-backup uploader records the last data it uploaded.
+const autoBackupStateFile = "auto_backup_state.json"
+
 func init() {
 	log.SetFlags(log.LstdFlags)
 	log.SetOutput(os.Stderr)
@@ -57,6 +63,13 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == backupOfflineCmd {
+		if err := backupOffline(context.Background(), os.Args[2:]); err != nil {
+			log.Fatalf("%s failed: %s", backupOfflineCmd, err.Error())
+		}
+		return
+	}
+
 	cfg, err := ParseFlags(name, desc, &BuildInfo{
 		Version:       cmd.Version,
 		Commit:        cmd.Commit,
@@ -86,7 +99,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to listen on %s: %s", cfg.RaftAddr, err.Error())
 	}
-	mux, err := startNodeMux(cfg, muxLn)
+	certMgr, err := createNodeCertManager(cfg)
+	if err != nil {
+		log.Fatalf("failed to create node certificate manager: %s", err.Error())
+	}
+	mux, err := startNodeMux(cfg, muxLn, certMgr)
 	if err != nil {
 		log.Fatalf("failed to start node mux: %s", err.Error())
 	}
@@ -132,6 +149,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to create cluster service: %s", err.Error())
 	}
+	if certMgr != nil {
+		clstrServ.SetCertManager(certMgr)
+	}
 	log.Printf("cluster TCP mux Listener registered with byte header %d", cluster.MuxClusterHeader)
 
 	// Create the HTTP service.
@@ -139,7 +159,7 @@ func main() {
 	// We want to start the HTTP server as soon as possible, so the node is responsive and external
 	// systems can see that it's running. We still have to open the Store though, so the node won't
 	// be able to do much until that happens however.
-	clstrClient, err := createClusterClient(cfg, clstrServ)
+	clstrClient, err := createClusterClient(cfg, clstrServ, certMgr)
 	if err != nil {
 		log.Fatalf("failed to create cluster client: %s", err.Error())
 	}
@@ -157,6 +177,9 @@ func main() {
 	// Register remaining status providers.
 	httpServ.RegisterStatus("cluster", clstrServ)
 	httpServ.RegisterStatus("network", tcp.NetworkReporter{})
+	if certMgr != nil {
+		httpServ.RegisterStatus("node_certs", certMgr)
+	}
 
 	// Prepare the cluster-joiner
 	joiner, err := createJoiner(cfg, credStr)
@@ -222,6 +245,9 @@ func main() {
 		log.Printf("failed to close store: %s", err.Error())
 	}
 	clstrServ.Close()
+	if certMgr != nil {
+		certMgr.Close()
+	}
 	muxLn.Close()
 	stopProfile()
 	log.Println("rqlite server stopped")
@@ -243,7 +269,13 @@ func startAutoBackups(ctx context.Context, cfg *Config, str *store.Store) (*back
 	}
 	sc := aws.NewS3Client(s3cfg.Endpoint, s3cfg.Region, s3cfg.AccessKeyID, s3cfg.SecretAccessKey,
 		s3cfg.Bucket, s3cfg.Path)
+	if err := sc.SetObjectLock(s3cfg.ObjectLock); err != nil {
+		return nil, fmt.Errorf("failed to configure auto-backup object lock: %s", err.Error())
+	}
 	u := backup.NewUploader(sc, str, time.Duration(uCfg.Interval), !uCfg.NoCompress)
+	if err := u.SetStatePath(filepath.Join(cfg.DataPath, autoBackupStateFile)); err != nil {
+		return nil, fmt.Errorf("failed to load auto-backup state: %s", err.Error())
+	}
 	go u.Start(ctx, nil)
 	return u, nil
 }
@@ -305,6 +337,8 @@ func createStore(cfg *Config, ln *tcp.Layer) (*store.Store, error) {
 	str.SetRequestCompression(cfg.CompressionBatch, cfg.CompressionSize)
 	str.RaftLogLevel = cfg.RaftLogLevel
 	str.NoFreeListSync = cfg.RaftNoFreelistSync
+	str.CoalesceBatchSz = cfg.WriteCoalesceBatchSz
+	str.CoalesceTimeout = cfg.WriteCoalesceTimeout
 	str.ShutdownOnRemove = cfg.RaftShutdownOnRemove
 	str.SnapshotThreshold = cfg.RaftSnapThreshold
 	str.SnapshotInterval = cfg.RaftSnapInterval
@@ -374,6 +408,14 @@ func startHTTPService(cfg *Config, str *store.Store, cltr *cluster.Client, credS
 	s.ClientVerify = cfg.HTTPVerifyClient
 	s.Expvar = cfg.Expvar
 	s.Pprof = cfg.PprofEnabled
+	s.AllowInsecureCertRotation = cfg.HTTPAllowInsecureCertRotation
+	if cfg.HTTPAccessLog != "" {
+		al, err := createAccessLogger(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP access logger: %s", err.Error())
+		}
+		s.AccessLogger = al
+	}
 	s.DefaultQueueCap = cfg.WriteQueueCap
 	s.DefaultQueueBatchSz = cfg.WriteQueueBatchSz
 	s.DefaultQueueTimeout = cfg.WriteQueueTimeout
@@ -388,16 +430,50 @@ func startHTTPService(cfg *Config, str *store.Store, cltr *cluster.Client, credS
 	return s, s.Start()
 }
 
+// createAccessLogger returns an HTTP access logger, writing to the file set
+// in the config.
+func createAccessLogger(cfg *Config) (*httpd.AccessLogger, error) {
+	var w io.Writer
+	switch cfg.HTTPAccessLog {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		f, err := os.OpenFile(cfg.HTTPAccessLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+	rates, err := httpd.ParseSampleRates(cfg.HTTPAccessLogSampleRates)
+	if err != nil {
+		return nil, err
+	}
+	return httpd.NewAccessLogger(w, cfg.HTTPAccessLogFormat, cfg.HTTPAccessLogSampleRate, rates)
+}
+
+// createNodeCertManager returns a certificate manager for node-to-node encryption,
+// allowing the node certificates to be rotated while the node is running. If
+// node-to-node encryption is not enabled, nil is returned.
+func createNodeCertManager(cfg *Config) (*rtls.CertManager, error) {
+	if cfg.NodeX509Cert == "" {
+		return nil, nil
+	}
+	return rtls.NewCertManager(cfg.NodeX509Cert, cfg.NodeX509Key, cfg.NodeX509CACert,
+		cfg.NoNodeVerify, cfg.NodeVerifyClient)
+}
+
 // startNodeMux starts the TCP mux on the given listener, which should be already
 // bound to the relevant interface.
-func startNodeMux(cfg *Config, ln net.Listener) (*tcp.Mux, error) {
+func startNodeMux(cfg *Config, ln net.Listener, certMgr *rtls.CertManager) (*tcp.Mux, error) {
 	var err error
 	adv := tcp.NameAddress{
 		Address: cfg.RaftAdv,
 	}
 
 	var mux *tcp.Mux
-	if cfg.NodeX509Cert != "" {
+	if certMgr != nil {
 		var b strings.Builder
 		b.WriteString(fmt.Sprintf("enabling node-to-node encryption with cert: %s, key: %s",
 			cfg.NodeX509Cert, cfg.NodeX509Key))
@@ -410,8 +486,7 @@ func startNodeMux(cfg *Config, ln net.Listener) (*tcp.Mux, error) {
 			b.WriteString(", mutual TLS enabled")
 		}
 		log.Println(b.String())
-		mux, err = tcp.NewTLSMux(ln, adv, cfg.NodeX509Cert, cfg.NodeX509Key, cfg.NodeX509CACert,
-			cfg.NoNodeVerify, cfg.NodeVerifyClient)
+		mux, err = tcp.NewTLSMuxWithConfig(ln, adv, certMgr.Config())
 	} else {
 		mux, err = tcp.NewMux(ln, adv)
 	}
@@ -456,10 +531,12 @@ func clusterService(cfg *Config, tn cluster.Transport, db cluster.Database, mgr
 	return c, nil
 }
 
-func createClusterClient(cfg *Config, clstr *cluster.Service) (*cluster.Client, error) {
+func createClusterClient(cfg *Config, clstr *cluster.Service, certMgr *rtls.CertManager) (*cluster.Client, error) {
 	var dialerTLSConfig *tls.Config
 	var err error
-	if cfg.NodeX509Cert != "" || cfg.NodeX509CACert != "" {
+	if certMgr != nil {
+		dialerTLSConfig = certMgr.Config()
+	} else if cfg.NodeX509CACert != "" {
 		dialerTLSConfig, err = rtls.CreateClientConfig(cfg.NodeX509Cert, cfg.NodeX509Key,
 			cfg.NodeX509CACert, cfg.NoNodeVerify)
 		if err != nil {
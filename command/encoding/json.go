@@ -23,6 +23,15 @@ type Result struct {
 	Time         float64 `json:"time,omitempty"`
 }
 
+// DetailedResult represents the outcome of an operation that changes rows, with
+// every field present even if zero.
+type DetailedResult struct {
+	LastInsertID int64   `json:"last_insert_id"`
+	RowsAffected int64   `json:"rows_affected"`
+	Error        string  `json:"error,omitempty"`
+	Time         float64 `json:"time"`
+}
+
 // Rows represents the outcome of an operation that returns query data.
 type Rows struct {
 	Columns []string        `json:"columns,omitempty"`
@@ -32,6 +41,17 @@ type Rows struct {
 	Time    float64         `json:"time,omitempty"`
 }
 
+// DetailedRows represents the outcome of an operation that returns query data,
+// with the time and number of rows returned present even if zero.
+type DetailedRows struct {
+	Columns      []string        `json:"columns,omitempty"`
+	Types        []string        `json:"types,omitempty"`
+	Values       [][]interface{} `json:"values,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Time         float64         `json:"time"`
+	RowsReturned int64           `json:"rows_returned"`
+}
+
 // AssociativeRows represents the outcome of an operation that returns query data.
 type AssociativeRows struct {
 	Types map[string]string        `json:"types,omitempty"`
@@ -40,6 +60,15 @@ type AssociativeRows struct {
 	Time  float64                  `json:"time,omitempty"`
 }
 
+// DetailedAssociativeRows is the detailed form of AssociativeRows.
+type DetailedAssociativeRows struct {
+	Types        map[string]string        `json:"types,omitempty"`
+	Rows         []map[string]interface{} `json:"rows"`
+	Error        string                   `json:"error,omitempty"`
+	Time         float64                  `json:"time"`
+	RowsReturned int64                    `json:"rows_returned"`
+}
+
 // ResultWithRows represents the outcome of an operation that changes rows, but also
 // includes an nil rows object, so clients can distinguish between a query and execute
 // result.
@@ -48,13 +77,26 @@ type ResultWithRows struct {
 	Rows []map[string]interface{} `json:"rows"`
 }
 
+// DetailedResultWithRows is the detailed form of ResultWithRows.
+type DetailedResultWithRows struct {
+	DetailedResult
+	Rows []map[string]interface{} `json:"rows"`
+}
+
 // NewResultRowsFromExecuteQueryResponse returns an API object from an
 // ExecuteQueryResponse.
 func NewResultRowsFromExecuteQueryResponse(e *command.ExecuteQueryResponse) (interface{}, error) {
+	return newResultRowsFromExecuteQueryResponse(e, false)
+}
+
+func newResultRowsFromExecuteQueryResponse(e *command.ExecuteQueryResponse, detailed bool) (interface{}, error) {
 	if er := e.GetE(); er != nil {
+		if detailed {
+			return NewDetailedResultFromExecuteResult(er)
+		}
 		return NewResultFromExecuteResult(er)
 	} else if qr := e.GetQ(); qr != nil {
-		return NewRowsFromQueryRows(qr)
+		return newRows(qr, false, detailed)
 	} else if err := e.GetError(); err != "" {
 		return map[string]string{
 			"error": err,
@@ -64,7 +106,20 @@ func NewResultRowsFromExecuteQueryResponse(e *command.ExecuteQueryResponse) (int
 }
 
 func NewAssociativeResultRowsFromExecuteQueryResponse(e *command.ExecuteQueryResponse) (interface{}, error) {
+	return newAssociativeResultRowsFromExecuteQueryResponse(e, false)
+}
+
+func newAssociativeResultRowsFromExecuteQueryResponse(e *command.ExecuteQueryResponse, detailed bool) (interface{}, error) {
 	if er := e.GetE(); er != nil {
+		if detailed {
+			r, err := NewDetailedResultFromExecuteResult(er)
+			if err != nil {
+				return nil, err
+			}
+			return &DetailedResultWithRows{
+				DetailedResult: *r,
+			}, nil
+		}
 		r, err := NewResultFromExecuteResult(er)
 		if err != nil {
 			return nil, err
@@ -73,7 +128,7 @@ func NewAssociativeResultRowsFromExecuteQueryResponse(e *command.ExecuteQueryRes
 			Result: *r,
 		}, nil
 	} else if qr := e.GetQ(); qr != nil {
-		return NewAssociativeRowsFromQueryRows(qr)
+		return newRows(qr, true, detailed)
 	} else if err := e.GetError(); err != "" {
 		return map[string]string{
 			"error": err,
@@ -92,6 +147,17 @@ func NewResultFromExecuteResult(e *command.ExecuteResult) (*Result, error) {
 	}, nil
 }
 
+// NewDetailedResultFromExecuteResult returns an API DetailedResult object from an
+// ExecuteResult.
+func NewDetailedResultFromExecuteResult(e *command.ExecuteResult) (*DetailedResult, error) {
+	return &DetailedResult{
+		LastInsertID: e.LastInsertId,
+		RowsAffected: e.RowsAffected,
+		Error:        e.Error,
+		Time:         e.Time,
+	}, nil
+}
+
 // NewRowsFromQueryRows returns an API Rows object from a QueryRows
 func NewRowsFromQueryRows(q *command.QueryRows) (*Rows, error) {
 	if len(q.Columns) != len(q.Types) {
@@ -188,11 +254,15 @@ func NewValuesFromQueryValues(dest [][]interface{}, v []*command.Values) error {
 // and ExecuteQueryRequests.
 type Encoder struct {
 	Associative bool
+
+	// Detailed includes the numeric fields of each statement's result, even if
+	// zero, and the number of rows returned by each query.
+	Detailed bool
 }
 
 // JSONMarshal implements the marshal interface
 func (e *Encoder) JSONMarshal(i interface{}) ([]byte, error) {
-	return jsonMarshal(i, noEscapeEncode, e.Associative)
+	return jsonMarshal(i, noEscapeEncode, e.Associative, e.Detailed)
 }
 
 // JSONMarshalIndent implements the marshal indent interface
@@ -206,7 +276,7 @@ func (e *Encoder) JSONMarshalIndent(i interface{}, prefix, indent string) ([]byt
 		json.Indent(&out, b, prefix, indent)
 		return out.Bytes(), nil
 	}
-	return jsonMarshal(i, f, e.Associative)
+	return jsonMarshal(i, f, e.Associative, e.Detailed)
 }
 
 func noEscapeEncode(i interface{}) ([]byte, error) {
@@ -221,88 +291,62 @@ func noEscapeEncode(i interface{}) ([]byte, error) {
 
 type marshalFunc func(i interface{}) ([]byte, error)
 
-func jsonMarshal(i interface{}, f marshalFunc, assoc bool) ([]byte, error) {
+func jsonMarshal(i interface{}, f marshalFunc, assoc, detailed bool) ([]byte, error) {
 	switch v := i.(type) {
 	case *command.ExecuteResult:
-		r, err := NewResultFromExecuteResult(v)
+		r, err := newResult(v, detailed)
 		if err != nil {
 			return nil, err
 		}
 		return f(r)
 	case []*command.ExecuteResult:
 		var err error
-		results := make([]*Result, len(v))
+		results := make([]interface{}, len(v))
 		for j := range v {
-			results[j], err = NewResultFromExecuteResult(v[j])
+			results[j], err = newResult(v[j], detailed)
 			if err != nil {
 				return nil, err
 			}
 		}
 		return f(results)
 	case *command.QueryRows:
-		if assoc {
-			r, err := NewAssociativeRowsFromQueryRows(v)
-			if err != nil {
-				return nil, err
-			}
-			return f(r)
-		} else {
-			r, err := NewRowsFromQueryRows(v)
-			if err != nil {
-				return nil, err
-			}
-			return f(r)
+		r, err := newRows(v, assoc, detailed)
+		if err != nil {
+			return nil, err
 		}
+		return f(r)
 	case *command.ExecuteQueryResponse:
-		r, err := NewResultRowsFromExecuteQueryResponse(v)
+		r, err := newResultRowsFromExecuteQueryResponse(v, detailed)
 		if err != nil {
 			return nil, err
 		}
 		return f(r)
 	case []*command.QueryRows:
 		var err error
-
-		if assoc {
-			rows := make([]*AssociativeRows, len(v))
-			for j := range v {
-				rows[j], err = NewAssociativeRowsFromQueryRows(v[j])
-				if err != nil {
-					return nil, err
-				}
-			}
-			return f(rows)
-		} else {
-			rows := make([]*Rows, len(v))
-			for j := range v {
-				rows[j], err = NewRowsFromQueryRows(v[j])
-				if err != nil {
-					return nil, err
-				}
+		rows := make([]interface{}, len(v))
+		for j := range v {
+			rows[j], err = newRows(v[j], assoc, detailed)
+			if err != nil {
+				return nil, err
 			}
-			return f(rows)
 		}
+		return f(rows)
 	case []*command.ExecuteQueryResponse:
-		if assoc {
-			res := make([]interface{}, len(v))
-			for j := range v {
-				r, err := NewAssociativeResultRowsFromExecuteQueryResponse(v[j])
-				if err != nil {
-					return nil, err
-				}
-				res[j] = r
+		res := make([]interface{}, len(v))
+		for j := range v {
+			var r interface{}
+			var err error
+			if assoc {
+				r, err = newAssociativeResultRowsFromExecuteQueryResponse(v[j], detailed)
+			} else {
+				r, err = newResultRowsFromExecuteQueryResponse(v[j], detailed)
 			}
-			return f(res)
-		} else {
-			res := make([]interface{}, len(v))
-			for j := range v {
-				r, err := NewResultRowsFromExecuteQueryResponse(v[j])
-				if err != nil {
-					return nil, err
-				}
-				res[j] = r
+			if err != nil {
+				return nil, err
 			}
-			return f(res)
+			res[j] = r
 		}
+		return f(res)
 	case []*command.Values:
 		values := make([][]interface{}, len(v))
 		if err := NewValuesFromQueryValues(values, v); err != nil {
@@ -313,3 +357,48 @@ func jsonMarshal(i interface{}, f marshalFunc, assoc bool) ([]byte, error) {
 		return f(v)
 	}
 }
+
+// newResult returns an API object from an ExecuteResult, in detailed form
+// if requested.
+func newResult(e *command.ExecuteResult, detailed bool) (interface{}, error) {
+	if detailed {
+		return NewDetailedResultFromExecuteResult(e)
+	}
+	return NewResultFromExecuteResult(e)
+}
+
+// newRows returns an API object from a QueryRows, in associative and detailed
+// form if requested.
+func newRows(q *command.QueryRows, assoc, detailed bool) (interface{}, error) {
+	if assoc {
+		r, err := NewAssociativeRowsFromQueryRows(q)
+		if err != nil {
+			return nil, err
+		}
+		if !detailed {
+			return r, nil
+		}
+		return &DetailedAssociativeRows{
+			Types:        r.Types,
+			Rows:         r.Rows,
+			Error:        r.Error,
+			Time:         r.Time,
+			RowsReturned: int64(len(q.Values)),
+		}, nil
+	}
+	r, err := NewRowsFromQueryRows(q)
+	if err != nil {
+		return nil, err
+	}
+	if !detailed {
+		return r, nil
+	}
+	return &DetailedRows{
+		Columns:      r.Columns,
+		Types:        r.Types,
+		Values:       r.Values,
+		Error:        r.Error,
+		Time:         r.Time,
+		RowsReturned: int64(len(q.Values)),
+	}, nil
+}
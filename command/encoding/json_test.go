@@ -596,3 +596,118 @@ func Test_MarshalExecuteQueryAssociativeResponse(t *testing.T) {
 		})
 	}
 }
+
+// Test_MarshalExecuteResultsDetailed tests JSON marshaling of ExecuteResults
+// with every per-statement field present.
+func Test_MarshalExecuteResultsDetailed(t *testing.T) {
+	enc := Encoder{
+		Detailed: true,
+	}
+
+	results := []*command.ExecuteResult{
+		{
+			LastInsertId: 1,
+			RowsAffected: 1,
+			Time:         0.5,
+		},
+		{
+			Time: 0.25,
+		},
+		{
+			Error: "no such table: bar",
+		},
+	}
+	b, err := enc.JSONMarshal(results)
+	if err != nil {
+		t.Fatalf("failed to marshal ExecuteResults: %s", err.Error())
+	}
+	if exp, got := `[{"last_insert_id":1,"rows_affected":1,"time":0.5},{"last_insert_id":0,"rows_affected":0,"time":0.25},{"last_insert_id":0,"rows_affected":0,"error":"no such table: bar","time":0}]`, string(b); exp != got {
+		t.Fatalf("failed to marshal ExecuteResults: exp %s, got %s", exp, got)
+	}
+}
+
+// Test_MarshalQueryRowsesDetailed tests JSON marshaling of a slice of QueryRows
+// with the time and number of rows returned by each query present even if zero.
+func Test_MarshalQueryRowsesDetailed(t *testing.T) {
+	rows := []*command.QueryRows{
+		{
+			Columns: []string{"c1"},
+			Types:   []string{"int"},
+			Values: []*command.Values{
+				{Parameters: []*command.Parameter{{Value: &command.Parameter_I{I: 1}}}},
+				{Parameters: []*command.Parameter{{Value: &command.Parameter_I{I: 2}}}},
+			},
+			Time: 0.5,
+		},
+		{
+			Columns: []string{"c1"},
+			Types:   []string{"int"},
+		},
+	}
+
+	enc := Encoder{
+		Detailed: true,
+	}
+	b, err := enc.JSONMarshal(rows)
+	if err != nil {
+		t.Fatalf("failed to marshal QueryRows: %s", err.Error())
+	}
+	if exp, got := `[{"columns":["c1"],"types":["int"],"values":[[1],[2]],"time":0.5,"rows_returned":2},{"columns":["c1"],"types":["int"],"time":0,"rows_returned":0}]`, string(b); exp != got {
+		t.Fatalf("failed to marshal QueryRows: exp %s, got %s", exp, got)
+	}
+
+	enc.Associative = true
+	b, err = enc.JSONMarshal(rows)
+	if err != nil {
+		t.Fatalf("failed to marshal QueryRows: %s", err.Error())
+	}
+	if exp, got := `[{"types":{"c1":"int"},"rows":[{"c1":1},{"c1":2}],"time":0.5,"rows_returned":2},{"types":{"c1":"int"},"rows":[],"time":0,"rows_returned":0}]`, string(b); exp != got {
+		t.Fatalf("failed to marshal associative QueryRows: exp %s, got %s", exp, got)
+	}
+}
+
+// Test_MarshalExecuteQueryResponseDetailed tests JSON marshaling of a slice of
+// ExecuteQueryResponses with every per-statement field present.
+func Test_MarshalExecuteQueryResponseDetailed(t *testing.T) {
+	resps := []*command.ExecuteQueryResponse{
+		{
+			Result: &command.ExecuteQueryResponse_Q{
+				Q: &command.QueryRows{
+					Columns: []string{"c1"},
+					Types:   []string{"int"},
+					Values: []*command.Values{
+						{Parameters: []*command.Parameter{{Value: &command.Parameter_I{I: 1}}}},
+					},
+					Time: 0.5,
+				},
+			},
+		},
+		{
+			Result: &command.ExecuteQueryResponse_E{
+				E: &command.ExecuteResult{
+					Time: 0.25,
+				},
+			},
+		},
+	}
+
+	enc := Encoder{
+		Detailed: true,
+	}
+	b, err := enc.JSONMarshal(resps)
+	if err != nil {
+		t.Fatalf("failed to marshal ExecuteQueryResponses: %s", err.Error())
+	}
+	if exp, got := `[{"columns":["c1"],"types":["int"],"values":[[1]],"time":0.5,"rows_returned":1},{"last_insert_id":0,"rows_affected":0,"time":0.25}]`, string(b); exp != got {
+		t.Fatalf("failed to marshal ExecuteQueryResponses: exp %s, got %s", exp, got)
+	}
+
+	enc.Associative = true
+	b, err = enc.JSONMarshal(resps)
+	if err != nil {
+		t.Fatalf("failed to marshal ExecuteQueryResponses: %s", err.Error())
+	}
+	if exp, got := `[{"types":{"c1":"int"},"rows":[{"c1":1}],"time":0.5,"rows_returned":1},{"last_insert_id":0,"rows_affected":0,"time":0.25,"rows":null}]`, string(b); exp != got {
+		t.Fatalf("failed to marshal associative ExecuteQueryResponses: exp %s, got %s", exp, got)
+	}
+}
@@ -738,14 +738,16 @@ func (db *DB) executeWithConn(req *command.Request, xTime bool, conn *sql.Conn)
 		return true
 	}
 
-	// Execute each statement.
-	for _, stmt := range req.Statements {
+	// Execute each statement. Each result records the index of its statement,
+	// since empty statements are skipped and produce no result.
+	for i, stmt := range req.Statements {
 		ss := stmt.Sql
 		if ss == "" {
 			continue
 		}
 
 		result, err := db.executeStmtWithConn(stmt, xTime, execer)
+		result.StmtIndex = int64(i)
 		if err != nil {
 			if handleError(result, err) {
 				continue
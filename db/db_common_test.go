@@ -104,6 +104,29 @@ func testEmptyStatements(t *testing.T, db *DB) {
 	if err != nil {
 		t.Fatalf("failed to execute empty statement with semicolon: %s", err.Error())
 	}
+
+	// Empty statements produce no result, so each result records the index
+	// of its statement.
+	req := &command.Request{
+		Statements: []*command.Statement{
+			{Sql: "CREATE TABLE foo (id INTEGER NOT NULL PRIMARY KEY, name TEXT)"},
+			{Sql: ""},
+			{Sql: `INSERT INTO foo(name) VALUES("fiona")`},
+			{Sql: `INSERT INTO bar(name) VALUES("fiona")`},
+		},
+	}
+	r, err := db.Execute(req, false)
+	if err != nil {
+		t.Fatalf("failed to execute statements: %s", err.Error())
+	}
+	if len(r) != 3 {
+		t.Fatalf("wrong number of results, exp 3, got %d", len(r))
+	}
+	for i, exp := range []int64{0, 2, 3} {
+		if got := r[i].StmtIndex; exp != got {
+			t.Fatalf("wrong statement index for result %d, exp %d, got %d", i, exp, got)
+		}
+	}
 }
 
 func testSimpleStatementsNumeric(t *testing.T, db *DB) {
@@ -0,0 +1,232 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AccessLogFormatCommon is the Common Log Format, with the request latency
+	// in seconds appended.
+	AccessLogFormatCommon = "common"
+
+	// AccessLogFormatCombined is the Combined Log Format, with the request latency
+	// in seconds appended.
+	AccessLogFormatCombined = "combined"
+
+	// AccessLogFormatJSON writes each request as a JSON object on a single line.
+	AccessLogFormatJSON = "json"
+
+	accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLogger writes a line to an io.Writer for each HTTP request served.
+// Requests may be sampled, so that only a fraction of requests is logged.
+// The sampling rate can be set for each endpoint.
+type AccessLogger struct {
+	format string
+	rate   float64
+	rates  []endpointRate // Sorted by prefix length, longest first.
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type endpointRate struct {
+	prefix string
+	rate   float64
+}
+
+// NewAccessLogger returns a new AccessLogger which writes to w in the given
+// format. rate is the fraction of requests logged, between 0 and 1 inclusive.
+// rates overrides rate for any request whose path starts with a key of the map.
+// If a path matches more than one key, the longest key is used.
+func NewAccessLogger(w io.Writer, format string, rate float64, rates map[string]float64) (*AccessLogger, error) {
+	switch format {
+	case AccessLogFormatCommon, AccessLogFormatCombined, AccessLogFormatJSON:
+	default:
+		return nil, fmt.Errorf("invalid access log format %q", format)
+	}
+	if err := checkSampleRate(rate); err != nil {
+		return nil, err
+	}
+
+	a := &AccessLogger{
+		format: format,
+		rate:   rate,
+		w:      w,
+	}
+	for p, r := range rates {
+		if err := checkSampleRate(r); err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err.Error())
+		}
+		a.rates = append(a.rates, endpointRate{prefix: p, rate: r})
+	}
+	sort.Slice(a.rates, func(i, j int) bool {
+		if len(a.rates[i].prefix) != len(a.rates[j].prefix) {
+			return len(a.rates[i].prefix) > len(a.rates[j].prefix)
+		}
+		return a.rates[i].prefix < a.rates[j].prefix
+	})
+	return a, nil
+}
+
+// ParseSampleRates parses a comma-delimited list of per-endpoint sampling rates,
+// each of the form <path prefix>=<rate>, for example "/db/query=0.1,/status=0".
+func ParseSampleRates(s string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	if strings.TrimSpace(s) == "" {
+		return rates, nil
+	}
+	for _, e := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(e), "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "/") {
+			return nil, fmt.Errorf("invalid sampling rate %q, must be of the form /path=rate", e)
+		}
+		r, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sampling rate %q: %s", e, err.Error())
+		}
+		if err := checkSampleRate(r); err != nil {
+			return nil, fmt.Errorf("%s: %s", kv[0], err.Error())
+		}
+		rates[kv[0]] = r
+	}
+	return rates, nil
+}
+
+// Sample returns whether the given request should be logged.
+func (a *AccessLogger) Sample(r *http.Request) bool {
+	rate := a.rateFor(r.URL.Path)
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	return rand.Float64() < rate
+}
+
+// Log writes a line for the given request, which resulted in the given
+// status code and response body size, and took latency to serve.
+func (a *AccessLogger) Log(r *http.Request, status int, size int64, start time.Time, latency time.Duration) error {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user, _, _ := r.BasicAuth()
+
+	var line []byte
+	switch a.format {
+	case AccessLogFormatJSON:
+		line, err = json.Marshal(struct {
+			Time      string  `json:"time"`
+			Remote    string  `json:"remote_addr"`
+			User      string  `json:"user,omitempty"`
+			Method    string  `json:"method"`
+			URI       string  `json:"uri"`
+			Proto     string  `json:"proto"`
+			Status    int     `json:"status"`
+			Size      int64   `json:"size"`
+			Referer   string  `json:"referer,omitempty"`
+			UserAgent string  `json:"user_agent,omitempty"`
+			Latency   float64 `json:"latency"`
+		}{
+			Time:      start.Format(time.RFC3339Nano),
+			Remote:    host,
+			User:      user,
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Proto:     r.Proto,
+			Status:    status,
+			Size:      size,
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+			Latency:   latency.Seconds(),
+		})
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+	default:
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s - %s [%s] %q %d %s", host, dashIfEmpty(user),
+			start.Format(accessLogTimeFormat), r.Method+" "+r.RequestURI+" "+r.Proto,
+			status, dashIfZero(size))
+		if a.format == AccessLogFormatCombined {
+			fmt.Fprintf(&b, " %q %q", dashIfEmpty(r.Referer()), dashIfEmpty(r.UserAgent()))
+		}
+		fmt.Fprintf(&b, " %.6f\n", latency.Seconds())
+		line = []byte(b.String())
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.w.Write(line)
+	return err
+}
+
+func (a *AccessLogger) rateFor(path string) float64 {
+	for _, er := range a.rates {
+		if strings.HasPrefix(path, er.prefix) {
+			return er.rate
+		}
+	}
+	return a.rate
+}
+
+// accessLogResponseWriter records the status code and body size of a response.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *accessLogResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func checkSampleRate(r float64) error {
+	if r < 0 || r > 1 {
+		return fmt.Errorf("sampling rate %v must be between 0 and 1", r)
+	}
+	return nil
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func dashIfZero(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
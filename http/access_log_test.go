@@ -0,0 +1,188 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NewAccessLoggerBadConfig(t *testing.T) {
+	if _, err := NewAccessLogger(&bytes.Buffer{}, "apache", 1, nil); err == nil {
+		t.Fatalf("expected error for invalid format")
+	}
+	if _, err := NewAccessLogger(&bytes.Buffer{}, AccessLogFormatCommon, 1.5, nil); err == nil {
+		t.Fatalf("expected error for invalid sampling rate")
+	}
+	if _, err := NewAccessLogger(&bytes.Buffer{}, AccessLogFormatCommon, 1, map[string]float64{"/status": -1}); err == nil {
+		t.Fatalf("expected error for invalid endpoint sampling rate")
+	}
+}
+
+func Test_ParseSampleRates(t *testing.T) {
+	rates, err := ParseSampleRates("")
+	if err != nil {
+		t.Fatalf("failed to parse empty sampling rates: %s", err.Error())
+	}
+	if len(rates) != 0 {
+		t.Fatalf("expected no sampling rates, got %v", rates)
+	}
+
+	rates, err = ParseSampleRates("/db/query=0.1, /status=0")
+	if err != nil {
+		t.Fatalf("failed to parse sampling rates: %s", err.Error())
+	}
+	if len(rates) != 2 || rates["/db/query"] != 0.1 || rates["/status"] != 0 {
+		t.Fatalf("wrong sampling rates, got %v", rates)
+	}
+
+	for _, s := range []string{
+		"/db/query",
+		"db/query=0.1",
+		"/db/query=foo",
+		"/db/query=2",
+	} {
+		if _, err := ParseSampleRates(s); err == nil {
+			t.Fatalf("expected error parsing %q", s)
+		}
+	}
+}
+
+func Test_AccessLoggerSample(t *testing.T) {
+	a, err := NewAccessLogger(&bytes.Buffer{}, AccessLogFormatCommon, 1, map[string]float64{
+		"/db":       0,
+		"/db/query": 1,
+		"/status":   0,
+	})
+	if err != nil {
+		t.Fatalf("failed to create access logger: %s", err.Error())
+	}
+
+	for path, exp := range map[string]bool{
+		"/db/query":   true,
+		"/db/execute": false,
+		"/status":     false,
+		"/nodes":      true,
+	} {
+		r := httptest.NewRequest("GET", path, nil)
+		if got := a.Sample(r); got != exp {
+			t.Fatalf("wrong sampling decision for %s, exp %v, got %v", path, exp, got)
+		}
+	}
+}
+
+func Test_AccessLoggerLogCommon(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a, err := NewAccessLogger(buf, AccessLogFormatCommon, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create access logger: %s", err.Error())
+	}
+
+	r := httptest.NewRequest("GET", "/db/query?q=SELECT%201", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.SetBasicAuth("fiona", "secret")
+	start := time.Date(2023, 3, 9, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+	if err := a.Log(r, http.StatusOK, 123, start, 1500*time.Microsecond); err != nil {
+		t.Fatalf("failed to write access log: %s", err.Error())
+	}
+	exp := `1.2.3.4 - fiona [09/Mar/2023:13:55:36 -0700] "GET /db/query?q=SELECT%201 HTTP/1.1" 200 123 0.001500` + "\n"
+	if got := buf.String(); got != exp {
+		t.Fatalf("wrong access log line\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+func Test_AccessLoggerLogCombined(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a, err := NewAccessLogger(buf, AccessLogFormatCombined, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create access logger: %s", err.Error())
+	}
+
+	r := httptest.NewRequest("POST", "/db/execute", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	r.Header.Set("User-Agent", "curl/7.88.1")
+	start := time.Date(2023, 3, 9, 13, 55, 36, 0, time.UTC)
+	if err := a.Log(r, 0, 0, start, time.Second); err != nil {
+		t.Fatalf("failed to write access log: %s", err.Error())
+	}
+	exp := `1.2.3.4 - - [09/Mar/2023:13:55:36 +0000] "POST /db/execute HTTP/1.1" 200 - "-" "curl/7.88.1" 1.000000` + "\n"
+	if got := buf.String(); got != exp {
+		t.Fatalf("wrong access log line\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+func Test_AccessLoggerLogJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a, err := NewAccessLogger(buf, AccessLogFormatJSON, 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create access logger: %s", err.Error())
+	}
+
+	r := httptest.NewRequest("GET", "/status", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	start := time.Date(2023, 3, 9, 13, 55, 36, 0, time.UTC)
+	if err := a.Log(r, http.StatusUnauthorized, 0, start, 250*time.Millisecond); err != nil {
+		t.Fatalf("failed to write access log: %s", err.Error())
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Fatalf("access log line not terminated by newline")
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("failed to unmarshal access log line: %s", err.Error())
+	}
+	for k, exp := range map[string]interface{}{
+		"time":        "2023-03-09T13:55:36Z",
+		"remote_addr": "1.2.3.4",
+		"method":      "GET",
+		"uri":         "/status",
+		"status":      float64(401),
+		"latency":     0.25,
+	} {
+		if got := m[k]; got != exp {
+			t.Fatalf("wrong value for %s, exp %v, got %v", k, exp, got)
+		}
+	}
+}
+
+func Test_AccessLogService(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a, err := NewAccessLogger(buf, AccessLogFormatCommon, 1, map[string]float64{"/readyz": 0})
+	if err != nil {
+		t.Fatalf("failed to create access logger: %s", err.Error())
+	}
+
+	m := &MockStore{}
+	c := &mockClusterService{}
+	s := New("127.0.0.1:0", m, c, nil)
+	s.AccessLogger = a
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+	for _, path := range []string{"/readyz", "/db/execute", "/status"} {
+		resp, err := http.Get(host + path)
+		if err != nil {
+			t.Fatalf("failed to make request: %s", err.Error())
+		}
+		resp.Body.Close()
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrong number of access log lines, exp 2, got %d: %s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], `"GET /db/execute HTTP/1.1" 405 -`) {
+		t.Fatalf("wrong access log line for /db/execute: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"GET /status HTTP/1.1" 200 `) {
+		t.Fatalf("wrong access log line for /status: %s", lines[1])
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"net/http/pprof"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -82,6 +83,13 @@ type Store interface {
 	// Remove removes the node from the cluster.
 	Remove(rn *command.RemoveNodeRequest) error
 
+	// Resync rebuilds the state of the node with the given ID from a snapshot
+	// of this node, which must be the Leader.
+	Resync(id string) error
+
+	// IsLeader returns whether this node is the leader of the cluster.
+	IsLeader() bool
+
 	// LeaderAddr returns the Raft address of the leader of the cluster.
 	LeaderAddr() (string, error)
 
@@ -121,6 +129,9 @@ type Cluster interface {
 	// RemoveNode removes a node from the cluster.
 	RemoveNode(rn *command.RemoveNodeRequest, nodeAddr string, creds *cluster.Credentials, timeout time.Duration) error
 
+	// RotateCerts performs one phase of a certificate rotation on a remote node.
+	RotateCerts(rr *cluster.RotateCertsRequest, nodeAddr string, creds *cluster.Credentials, timeout time.Duration) error
+
 	// Stats returns stats on the Cluster.
 	Stats() (map[string]interface{}, error)
 }
@@ -144,6 +155,7 @@ type DBResults struct {
 	ExecuteQueryResponse []*command.ExecuteQueryResponse
 
 	AssociativeJSON bool // Render in associative form
+	Detailed        bool // Render every per-statement field, set when timings are requested
 }
 
 // Responser is the interface response objects must implement.
@@ -155,6 +167,7 @@ type Responser interface {
 func (d *DBResults) MarshalJSON() ([]byte, error) {
 	enc := encoding.Encoder{
 		Associative: d.AssociativeJSON,
+		Detailed:    d.Detailed,
 	}
 
 	if d.ExecuteResult != nil {
@@ -222,6 +235,8 @@ const (
 	numRemoteBackups                  = "remote_backups"
 	numRemoteLoads                    = "remote_loads"
 	numRemoteRemoveNode               = "remote_remove_node"
+	numRotateCerts                    = "rotate_certs"
+	numResyncs                        = "resyncs"
 	numReadyz                         = "num_readyz"
 	numStatus                         = "num_status"
 	numBackups                        = "backups"
@@ -277,6 +292,8 @@ func ResetStats() {
 	stats.Add(numRemoteBackups, 0)
 	stats.Add(numRemoteLoads, 0)
 	stats.Add(numRemoteRemoveNode, 0)
+	stats.Add(numRotateCerts, 0)
+	stats.Add(numResyncs, 0)
 	stats.Add(numReadyz, 0)
 	stats.Add(numStatus, 0)
 	stats.Add(numBackups, 0)
@@ -326,6 +343,12 @@ type Service struct {
 	Expvar bool
 	Pprof  bool
 
+	// AllowInsecureCertRotation allows certificate rotation requests, which carry
+	// private keys, even if HTTPS is not enabled.
+	AllowInsecureCertRotation bool
+
+	AccessLogger *AccessLogger // Logs HTTP requests, if set.
+
 	BuildInfo map[string]interface{}
 
 	logger *log.Logger
@@ -427,6 +450,16 @@ func (s *Service) HTTPS() bool {
 
 // ServeHTTP allows Service to serve HTTP requests.
 func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.AccessLogger != nil && s.AccessLogger.Sample(r) {
+		start := time.Now()
+		lw := &accessLogResponseWriter{ResponseWriter: w}
+		defer func() {
+			if err := s.AccessLogger.Log(r, lw.status, lw.size, start, time.Since(start)); err != nil {
+				s.logger.Printf("failed to write access log: %s", err.Error())
+			}
+		}()
+		w = lw
+	}
 	s.addBuildVersion(w)
 
 	switch {
@@ -455,6 +488,12 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handleNotify(w, r)
 	case strings.HasPrefix(r.URL.Path, "/remove"):
 		s.handleRemove(w, r)
+	case strings.HasPrefix(r.URL.Path, "/certs/rotate"):
+		stats.Add(numRotateCerts, 1)
+		s.handleRotateCerts(w, r)
+	case strings.HasPrefix(r.URL.Path, "/resync"):
+		stats.Add(numResyncs, 1)
+		s.handleResync(w, r)
 	case strings.HasPrefix(r.URL.Path, "/status"):
 		stats.Add(numStatus, 1)
 		s.handleStatus(w, r)
@@ -727,6 +766,62 @@ func (s *Service) handleRemove(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleResync rebuilds the state of a node from a fresh snapshot of the
+// Leader, discarding the node's existing database. This must be performed
+// on the Leader, and any other node redirects the client to the Leader.
+func (s *Service) handleResync(w http.ResponseWriter, r *http.Request) {
+	if !s.CheckRequestPerm(r, auth.PermResync) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	m := map[string]string{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(m) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	remoteID, ok := m["id"]
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = s.store.Resync(remoteID)
+	if err != nil {
+		if err == store.ErrNotLeader {
+			leaderAPIAddr := s.LeaderAPIAddr()
+			if leaderAPIAddr == "" {
+				stats.Add(numLeaderNotFound, 1)
+				http.Error(w, ErrLeaderNotFound.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			// Temporary Redirect ensures the client resends the body.
+			http.Redirect(w, r, s.FormRedirect(r, leaderAPIAddr), http.StatusTemporaryRedirect)
+			return
+		}
+		if err == store.ErrResyncLeader {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 // handleBackup returns the consistent database snapshot.
 func (s *Service) handleBackup(w http.ResponseWriter, r *http.Request) {
 	if !s.CheckRequestPerm(r, auth.PermBackup) {
@@ -1115,6 +1210,178 @@ func (s *Service) handleStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleRotateCerts rotates the certificates used between nodes. The new CA
+// certificate is first staged on every node, so that every node trusts it.
+// Only then is the new certificate activated on each node. Each node stops
+// trusting the previous CA certificates once the overlap period has elapsed.
+// This must be performed on the Leader, and any other node redirects the
+// client to the Leader.
+func (s *Service) handleRotateCerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if !s.CheckRequestPerm(r, auth.PermRotateCerts) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The request carries private keys, so must not be sent in the clear.
+	if !s.HTTPS() && !s.AllowInsecureCertRotation {
+		http.Error(w, "certificate rotation requires HTTPS", http.StatusForbidden)
+		return
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req := struct {
+		CACert  string `json:"ca_cert,omitempty"`
+		Cert    string `json:"cert,omitempty"`
+		Key     string `json:"key,omitempty"`
+		Overlap string `json:"overlap,omitempty"`
+		Nodes   map[string]struct {
+			Cert string `json:"cert"`
+			Key  string `json:"key"`
+		} `json:"nodes,omitempty"`
+	}{}
+	if err := json.Unmarshal(b, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var overlap time.Duration
+	if req.Overlap != "" {
+		overlap, err = time.ParseDuration(req.Overlap)
+		if err != nil || overlap < 0 {
+			http.Error(w, fmt.Sprintf("invalid overlap %q", req.Overlap), http.StatusBadRequest)
+			return
+		}
+	}
+
+	timeout, err := timeoutParam(r, defaultTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !s.store.IsLeader() {
+		leaderAPIAddr := s.LeaderAPIAddr()
+		if leaderAPIAddr == "" {
+			stats.Add(numLeaderNotFound, 1)
+			http.Error(w, ErrLeaderNotFound.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		// Temporary Redirect ensures the client resends the body.
+		http.Redirect(w, r, s.FormRedirect(r, leaderAPIAddr), http.StatusTemporaryRedirect)
+		return
+	}
+
+	nodes, err := s.store.Nodes()
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err == store.ErrNotOpen {
+			statusCode = http.StatusServiceUnavailable
+		}
+		http.Error(w, fmt.Sprintf("store nodes: %s", err.Error()), statusCode)
+		return
+	}
+	lAddr, err := s.store.LeaderAddr()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("leader address: %s", err.Error()),
+			http.StatusInternalServerError)
+		return
+	}
+
+	// Every node must have a certificate to activate. The Leader is activated
+	// last, so it continues to present its existing certificate for as long
+	// as possible.
+	activates := make(map[string]*cluster.RotateCertsRequest, len(nodes))
+	for _, n := range nodes {
+		cert, key := req.Cert, req.Key
+		if nc, ok := req.Nodes[n.ID]; ok {
+			cert, key = nc.Cert, nc.Key
+		}
+		if cert == "" || key == "" {
+			http.Error(w, fmt.Sprintf("no certificate and key set for node %s", n.ID), http.StatusBadRequest)
+			return
+		}
+		activates[n.ID] = &cluster.RotateCertsRequest{
+			Phase:   cluster.RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_ACTIVATE,
+			Cert:    []byte(cert),
+			Key:     []byte(key),
+			Overlap: overlap.Nanoseconds(),
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Addr != lAddr && nodes[j].Addr == lAddr
+	})
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		username = ""
+	}
+	creds := makeCredentials(username, password)
+
+	resp := make(map[string]struct {
+		Addr    string `json:"addr,omitempty"`
+		Staged  bool   `json:"staged"`
+		Rotated bool   `json:"rotated"`
+		Error   string `json:"error,omitempty"`
+	})
+
+	rotate := func(phase string, rrFn func(n *store.Server) *cluster.RotateCertsRequest) bool {
+		success := true
+		for _, n := range nodes {
+			nr := resp[n.ID]
+			nr.Addr = n.Addr
+			if err := s.cluster.RotateCerts(rrFn(n), n.Addr, creds, timeout); err != nil {
+				nr.Error = fmt.Sprintf("%s: %s", phase, err.Error())
+				success = false
+			} else if phase == "stage" {
+				nr.Staged = true
+			} else {
+				nr.Rotated = true
+			}
+			resp[n.ID] = nr
+		}
+		return success
+	}
+
+	// Only activate new certificates if every node trusts the new CA.
+	stageReq := &cluster.RotateCertsRequest{
+		Phase:  cluster.RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_STAGE,
+		CaCert: []byte(req.CACert),
+	}
+	statusCode := http.StatusOK
+	if !rotate("stage", func(*store.Server) *cluster.RotateCertsRequest { return stageReq }) {
+		statusCode = http.StatusInternalServerError
+	} else if !rotate("activate", func(n *store.Server) *cluster.RotateCertsRequest { return activates[n.ID] }) {
+		statusCode = http.StatusInternalServerError
+	}
+
+	pretty, _ := isPretty(r)
+	if pretty {
+		b, err = json.MarshalIndent(resp, "", "    ")
+	} else {
+		b, err = json.Marshal(resp)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(statusCode)
+	_, err = w.Write(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 // handleNodes returns status on the other voting nodes in the system.
 // This attempts to contact all the nodes in the cluster, so may take
 // some time to return.
@@ -1394,6 +1661,7 @@ func (s *Service) execute(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	resp.Results.Detailed = timings
 
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -1512,6 +1780,7 @@ func (s *Service) handleQuery(w http.ResponseWriter, r *http.Request) {
 
 	resp := NewResponse()
 	resp.Results.AssociativeJSON = isAssoc
+	resp.Results.Detailed = timings
 
 	qr := &command.QueryRequest{
 		Request: &command.Request{
@@ -1613,6 +1882,7 @@ func (s *Service) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	resp := NewResponse()
 	resp.Results.AssociativeJSON = isAssoc
+	resp.Results.Detailed = timings
 
 	eqr := &command.ExecuteQueryRequest{
 		Request: &command.Request{
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -283,6 +284,22 @@ func Test_405Routes(t *testing.T) {
 		t.Fatalf("failed to get expected 405, got %d", resp.StatusCode)
 	}
 
+	resp, err = client.Get(host + "/certs/rotate")
+	if err != nil {
+		t.Fatalf("failed to make request")
+	}
+	if resp.StatusCode != 405 {
+		t.Fatalf("failed to get expected 405, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(host + "/resync")
+	if err != nil {
+		t.Fatalf("failed to make request")
+	}
+	if resp.StatusCode != 405 {
+		t.Fatalf("failed to get expected 405, got %d", resp.StatusCode)
+	}
+
 	resp, err = client.Get(host + "/join")
 	if err != nil {
 		t.Fatalf("failed to make request")
@@ -362,6 +379,8 @@ func Test_401Routes_NoBasicAuth(t *testing.T) {
 		"/join",
 		"/notify",
 		"/remove",
+		"/certs/rotate",
+		"/resync",
 		"/status",
 		"/nodes",
 		"/readyz",
@@ -560,6 +579,254 @@ func Test_401JoinReadOnly(t *testing.T) {
 	}
 }
 
+func Test_RotateCertsOK(t *testing.T) {
+	m := &MockStore{
+		leaderAddr: "localhost:2",
+		nodes: []*store.Server{
+			{ID: "node1", Addr: "localhost:1"},
+			{ID: "node2", Addr: "localhost:2"},
+			{ID: "node3", Addr: "localhost:3"},
+		},
+	}
+	c := &mockClusterService{}
+	s := New("127.0.0.1:0", m, c, nil)
+	s.AllowInsecureCertRotation = true
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+
+	var calls []string
+	c.rotateCertsFn = func(rr *cluster.RotateCertsRequest, nodeAddr string, t time.Duration) error {
+		switch rr.Phase {
+		case cluster.RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_STAGE:
+			if string(rr.CaCert) != "ca" {
+				return fmt.Errorf("wrong CA certificate")
+			}
+			calls = append(calls, "stage "+nodeAddr)
+		case cluster.RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_ACTIVATE:
+			if nodeAddr == "localhost:3" && string(rr.Cert) != "cert3" {
+				return fmt.Errorf("wrong certificate for node3")
+			}
+			if nodeAddr != "localhost:3" && string(rr.Cert) != "cert" {
+				return fmt.Errorf("wrong certificate for %s", nodeAddr)
+			}
+			if rr.Overlap != time.Hour.Nanoseconds() {
+				return fmt.Errorf("wrong overlap")
+			}
+			calls = append(calls, "activate "+nodeAddr)
+		}
+		return nil
+	}
+
+	body := `{"ca_cert":"ca","cert":"cert","key":"key","overlap":"1h","nodes":{"node3":{"cert":"cert3","key":"key3"}}}`
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+	resp, err := http.Post(host+"/certs/rotate", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to make rotate request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		t.Fatalf("failed to get expected StatusOK for rotate, got %d: %s", resp.StatusCode, b)
+	}
+
+	// Every node stages the CA before any activates, and the leader activates last.
+	exp := []string{
+		"stage localhost:1", "stage localhost:3", "stage localhost:2",
+		"activate localhost:1", "activate localhost:3", "activate localhost:2",
+	}
+	if !reflect.DeepEqual(calls, exp) {
+		t.Fatalf("wrong rotation calls, exp %v, got %v", exp, calls)
+	}
+}
+
+func Test_RotateCertsRequiresHTTPS(t *testing.T) {
+	m := &MockStore{
+		leaderAddr: "localhost:1",
+	}
+	c := &mockClusterService{}
+	c.rotateCertsFn = func(rr *cluster.RotateCertsRequest, nodeAddr string, t time.Duration) error {
+		return fmt.Errorf("rotation attempted over HTTP")
+	}
+	s := New("127.0.0.1:0", m, c, nil)
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+
+	body := `{"ca_cert":"ca","cert":"cert","key":"key"}`
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+	resp, err := http.Post(host+"/certs/rotate", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to make rotate request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("failed to get expected StatusForbidden for rotate over HTTP, got %d", resp.StatusCode)
+	}
+}
+
+func Test_RotateCertsStageFail(t *testing.T) {
+	m := &MockStore{
+		leaderAddr: "localhost:1",
+		nodes: []*store.Server{
+			{ID: "node1", Addr: "localhost:1"},
+			{ID: "node2", Addr: "localhost:2"},
+		},
+	}
+	c := &mockClusterService{}
+	s := New("127.0.0.1:0", m, c, nil)
+	s.AllowInsecureCertRotation = true
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+
+	c.rotateCertsFn = func(rr *cluster.RotateCertsRequest, nodeAddr string, t time.Duration) error {
+		if rr.Phase == cluster.RotateCertsRequest_ROTATE_CERTS_REQUEST_PHASE_ACTIVATE {
+			return fmt.Errorf("activate called after failed stage")
+		}
+		if nodeAddr == "localhost:2" {
+			return fmt.Errorf("unreachable")
+		}
+		return nil
+	}
+
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+	resp, err := http.Post(host+"/certs/rotate", "application/json",
+		strings.NewReader(`{"ca_cert":"ca","cert":"cert","key":"key"}`))
+	if err != nil {
+		t.Fatalf("failed to make rotate request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("failed to get expected StatusInternalServerError for rotate, got %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %s", err.Error())
+	}
+	if exp, got := `{"node1":{"addr":"localhost:1","staged":true,"rotated":false},"node2":{"addr":"localhost:2","staged":false,"rotated":false,"error":"stage: unreachable"}}`, string(b); exp != got {
+		t.Fatalf("wrong response body, exp %s, got %s", exp, got)
+	}
+}
+
+func Test_RotateCertsNoLeaderRedirect(t *testing.T) {
+	m := &MockStore{
+		notLeader: true,
+	}
+	c := &mockClusterService{
+		apiAddr: "http://1.2.3.4:999",
+	}
+	s := New("127.0.0.1:0", m, c, nil)
+	s.AllowInsecureCertRotation = true
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+
+	client := &http.Client{}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+	resp, err := client.Post(host+"/certs/rotate", "application/json",
+		strings.NewReader(`{"cert":"cert","key":"key"}`))
+	if err != nil {
+		t.Fatalf("failed to make rotate request: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("failed to get expected StatusTemporaryRedirect for rotate, got %d", resp.StatusCode)
+	}
+	if exp, got := "http://1.2.3.4:999/certs/rotate", resp.Header.Get("Location"); exp != got {
+		t.Fatalf("wrong redirect location, exp %s, got %s", exp, got)
+	}
+}
+
+func Test_ResyncOK(t *testing.T) {
+	m := &MockStore{}
+	c := &mockClusterService{}
+	s := New("127.0.0.1:0", m, c, nil)
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+
+	var resyncID string
+	m.resyncFn = func(id string) error {
+		resyncID = id
+		return nil
+	}
+
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+	resp, err := http.Post(host+"/resync", "application/json", strings.NewReader(`{"id":"node2"}`))
+	if err != nil {
+		t.Fatalf("failed to make resync request: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to get expected StatusOK for resync, got %d", resp.StatusCode)
+	}
+	if resyncID != "node2" {
+		t.Fatalf("wrong node resynced, exp node2, got %s", resyncID)
+	}
+
+	for _, body := range []string{``, `{}`, `{"node":"node2"}`} {
+		resp, err = http.Post(host+"/resync", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to make resync request: %s", err.Error())
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("failed to get expected StatusBadRequest for body %q, got %d", body, resp.StatusCode)
+		}
+	}
+
+	m.resyncFn = func(id string) error {
+		return store.ErrResyncLeader
+	}
+	resp, err = http.Post(host+"/resync", "application/json", strings.NewReader(`{"id":"node1"}`))
+	if err != nil {
+		t.Fatalf("failed to make resync request: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("failed to get expected StatusBadRequest resyncing Leader, got %d", resp.StatusCode)
+	}
+}
+
+func Test_ResyncNoLeaderRedirect(t *testing.T) {
+	m := &MockStore{
+		resyncFn: func(id string) error {
+			return store.ErrNotLeader
+		},
+	}
+	c := &mockClusterService{
+		apiAddr: "http://1.2.3.4:999",
+	}
+	s := New("127.0.0.1:0", m, c, nil)
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+
+	client := &http.Client{}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+	resp, err := client.Post(host+"/resync", "application/json", strings.NewReader(`{"id":"node2"}`))
+	if err != nil {
+		t.Fatalf("failed to make resync request: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("failed to get expected StatusTemporaryRedirect for resync, got %d", resp.StatusCode)
+	}
+	if exp, got := "http://1.2.3.4:999/resync", resp.Header.Get("Location"); exp != got {
+		t.Fatalf("wrong redirect location, exp %s, got %s", exp, got)
+	}
+}
+
 func Test_BackupOK(t *testing.T) {
 	m := &MockStore{}
 	c := &mockClusterService{}
@@ -1215,6 +1482,89 @@ func Test_ForwardingRedirectExecuteQuery(t *testing.T) {
 	}
 }
 
+func Test_ExecuteTimings(t *testing.T) {
+	m := &MockStore{
+		leaderAddr: "foo:1234",
+	}
+	m.executeFn = func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error) {
+		return []*command.ExecuteResult{
+			{LastInsertId: 1, RowsAffected: 1, Time: 0.5},
+			{Time: 0.25},
+		}, nil
+	}
+	c := &mockClusterService{}
+	s := New("127.0.0.1:0", m, c, nil)
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+
+	// With timings every field of each result is present, even if zero.
+	results := mustPostResults(t, host+"/db/execute?timings", `["stmt1", "stmt2"]`)
+	if exp, got := `[{"last_insert_id":1,"rows_affected":1,"time":0.5},{"last_insert_id":0,"rows_affected":0,"time":0.25}]`, results; exp != got {
+		t.Fatalf("unexpected results with timings\nexp: %s\ngot: %s", exp, got)
+	}
+
+	// Without timings only non-zero fields are present.
+	results = mustPostResults(t, host+"/db/execute", `["stmt1", "stmt2"]`)
+	if exp, got := `[{"last_insert_id":1,"rows_affected":1,"time":0.5},{"time":0.25}]`, results; exp != got {
+		t.Fatalf("unexpected results without timings\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+func Test_RequestTimings(t *testing.T) {
+	m := &MockStore{
+		leaderAddr: "foo:1234",
+	}
+	m.requestFn = func(er *command.ExecuteQueryRequest) ([]*command.ExecuteQueryResponse, error) {
+		return []*command.ExecuteQueryResponse{
+			{
+				Result: &command.ExecuteQueryResponse_E{
+					E: &command.ExecuteResult{Time: 0.5},
+				},
+			},
+			{
+				Result: &command.ExecuteQueryResponse_Q{
+					Q: &command.QueryRows{
+						Columns: []string{"id"},
+						Types:   []string{"integer"},
+						Values: []*command.Values{
+							{Parameters: []*command.Parameter{{Value: &command.Parameter_I{I: 1}}}},
+							{Parameters: []*command.Parameter{{Value: &command.Parameter_I{I: 2}}}},
+						},
+						Time: 0.25,
+					},
+				},
+			},
+		}, nil
+	}
+	c := &mockClusterService{}
+	s := New("127.0.0.1:0", m, c, nil)
+	if err := s.Start(); err != nil {
+		t.Fatalf("failed to start service")
+	}
+	defer s.Close()
+	host := fmt.Sprintf("http://%s", s.Addr().String())
+
+	// With timings every field of each result is present, even if zero, and
+	// each query reports the number of rows it returned.
+	results := mustPostResults(t, host+"/db/request?timings", `["stmt1", "stmt2"]`)
+	if exp, got := `[{"last_insert_id":0,"rows_affected":0,"time":0.5},{"columns":["id"],"types":["integer"],"values":[[1],[2]],"time":0.25,"rows_returned":2}]`, results; exp != got {
+		t.Fatalf("unexpected results with timings\nexp: %s\ngot: %s", exp, got)
+	}
+	results = mustPostResults(t, host+"/db/request?timings&associative", `["stmt1", "stmt2"]`)
+	if exp, got := `[{"last_insert_id":0,"rows_affected":0,"time":0.5,"rows":null},{"types":{"id":"integer"},"rows":[{"id":1},{"id":2}],"time":0.25,"rows_returned":2}]`, results; exp != got {
+		t.Fatalf("unexpected associative results with timings\nexp: %s\ngot: %s", exp, got)
+	}
+
+	// Without timings only non-zero fields are present.
+	results = mustPostResults(t, host+"/db/request", `["stmt1", "stmt2"]`)
+	if exp, got := `[{"time":0.5},{"columns":["id"],"types":["integer"],"values":[[1],[2]],"time":0.25}]`, results; exp != got {
+		t.Fatalf("unexpected results without timings\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
 func Test_timeoutQueryParam(t *testing.T) {
 	var req http.Request
 
@@ -1263,14 +1613,41 @@ func Test_timeoutQueryParam(t *testing.T) {
 	}
 }
 
+// mustPostResults POSTs the body to the given URL, and returns the "results"
+// member of the JSON response.
+func mustPostResults(t *testing.T, url, body string) string {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to make request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to get expected StatusOK, got %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %s", err.Error())
+	}
+	var r struct {
+		Results json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatalf("failed to unmarshal response %s: %s", b, err.Error())
+	}
+	return string(r.Results)
+}
+
 type MockStore struct {
 	executeFn   func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error)
 	queryFn     func(qr *command.QueryRequest) ([]*command.QueryRows, error)
 	requestFn   func(eqr *command.ExecuteQueryRequest) ([]*command.ExecuteQueryResponse, error)
 	backupFn    func(br *command.BackupRequest, dst io.Writer) error
 	loadChunkFn func(lr *command.LoadChunkRequest) error
+	resyncFn    func(id string) error
 	leaderAddr  string
+	nodes       []*store.Server
 	notReady    bool // Default value is true, easier to test.
+	notLeader   bool // Default value is true, easier to test.
 }
 
 func (m *MockStore) Execute(er *command.ExecuteRequest) ([]*command.ExecuteResult, error) {
@@ -1306,6 +1683,13 @@ func (m *MockStore) Remove(rn *command.RemoveNodeRequest) error {
 	return nil
 }
 
+func (m *MockStore) Resync(id string) error {
+	if m.resyncFn != nil {
+		return m.resyncFn(id)
+	}
+	return nil
+}
+
 func (m *MockStore) LeaderAddr() (string, error) {
 	return m.leaderAddr, nil
 }
@@ -1314,12 +1698,16 @@ func (m *MockStore) Ready() bool {
 	return !m.notReady
 }
 
+func (m *MockStore) IsLeader() bool {
+	return !m.notLeader
+}
+
 func (m *MockStore) Stats() (map[string]interface{}, error) {
 	return nil, nil
 }
 
 func (m *MockStore) Nodes() ([]*store.Server, error) {
-	return nil, nil
+	return m.nodes, nil
 }
 
 func (m *MockStore) Backup(br *command.BackupRequest, w io.Writer) error {
@@ -1337,13 +1725,14 @@ func (m *MockStore) LoadChunk(lc *command.LoadChunkRequest) error {
 }
 
 type mockClusterService struct {
-	apiAddr      string
-	executeFn    func(er *command.ExecuteRequest, addr string, t time.Duration) ([]*command.ExecuteResult, error)
-	queryFn      func(qr *command.QueryRequest, addr string, t time.Duration) ([]*command.QueryRows, error)
-	requestFn    func(eqr *command.ExecuteQueryRequest, nodeAddr string, timeout time.Duration) ([]*command.ExecuteQueryResponse, error)
-	backupFn     func(br *command.BackupRequest, addr string, t time.Duration, w io.Writer) error
-	loadChunkFn  func(lc *command.LoadChunkRequest, addr string, t time.Duration) error
-	removeNodeFn func(rn *command.RemoveNodeRequest, nodeAddr string, t time.Duration) error
+	apiAddr       string
+	executeFn     func(er *command.ExecuteRequest, addr string, t time.Duration) ([]*command.ExecuteResult, error)
+	queryFn       func(qr *command.QueryRequest, addr string, t time.Duration) ([]*command.QueryRows, error)
+	requestFn     func(eqr *command.ExecuteQueryRequest, nodeAddr string, timeout time.Duration) ([]*command.ExecuteQueryResponse, error)
+	backupFn      func(br *command.BackupRequest, addr string, t time.Duration, w io.Writer) error
+	loadChunkFn   func(lc *command.LoadChunkRequest, addr string, t time.Duration) error
+	removeNodeFn  func(rn *command.RemoveNodeRequest, nodeAddr string, t time.Duration) error
+	rotateCertsFn func(rr *cluster.RotateCertsRequest, nodeAddr string, t time.Duration) error
 }
 
 func (m *mockClusterService) GetNodeAPIAddr(a string, t time.Duration) (string, error) {
@@ -1392,6 +1781,13 @@ func (m *mockClusterService) RemoveNode(rn *command.RemoveNodeRequest, addr stri
 	return nil
 }
 
+func (m *mockClusterService) RotateCerts(rr *cluster.RotateCertsRequest, addr string, creds *cluster.Credentials, t time.Duration) error {
+	if m.rotateCertsFn != nil {
+		return m.rotateCertsFn(rr, addr, t)
+	}
+	return nil
+}
+
 type mockCredentialStore struct {
 	HasPermOK bool
 	aaFunc    func(username, password, perm string) bool
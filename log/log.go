@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/raft"
 	"github.com/rqlite/raft-boltdb/v2"
@@ -36,6 +37,24 @@ func New(path string, noFreelistSync bool) (*Log, error) {
 	return &Log{bs}, nil
 }
 
+// NewReadOnly returns an instantiated Log object that provides read-only access
+// to the Raft log stored in a BoltDB database. If the database is locked by
+// another process, such as a running rqlite node, an error is returned once
+// timeout has elapsed.
+func NewReadOnly(path string, timeout time.Duration) (*Log, error) {
+	bs, err := raftboltdb.New(raftboltdb.Options{
+		BoltOptions: &bbolt.Options{
+			ReadOnly: true,
+			Timeout:  timeout,
+		},
+		Path: path,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("new read-only bbolt store: %s", err)
+	}
+	return &Log{bs}, nil
+}
+
 // Indexes returns the first and last indexes.
 func (l *Log) Indexes() (uint64, uint64, error) {
 	fi, err := l.FirstIndex()
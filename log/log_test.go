@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/raft"
 	raftboltdb "github.com/rqlite/raft-boltdb/v2"
@@ -42,6 +43,44 @@ func Test_LogNewEmpty(t *testing.T) {
 	}
 }
 
+func Test_LogNewReadOnly(t *testing.T) {
+	path := mustTempFile()
+	defer os.Remove(path)
+
+	bs, err := raftboltdb.NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("failed to create bolt store: %s", err)
+	}
+	for i := 4; i > 0; i-- {
+		if err := bs.StoreLog(&raft.Log{
+			Index: uint64(i),
+		}); err != nil {
+			t.Fatalf("failed to write entry to raft log: %s", err)
+		}
+	}
+
+	// The log is locked by the open store, so opening read-only must time out.
+	if _, err := NewReadOnly(path, 100*time.Millisecond); err == nil {
+		t.Fatalf("expected error opening locked log read-only")
+	}
+	if err := bs.Close(); err != nil {
+		t.Fatalf("failed to close bolt db: %s", err)
+	}
+
+	l, err := NewReadOnly(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create read-only log: %s", err)
+	}
+	defer l.Close()
+	fi, li, err := l.Indexes()
+	if err != nil {
+		t.Fatalf("failed to get indexes: %s", err)
+	}
+	if fi != 1 || li != 4 {
+		t.Fatalf("got wrong indexes for read-only log, first: %d, last: %d", fi, li)
+	}
+}
+
 func Test_LogNewExistNotEmpty(t *testing.T) {
 	path := mustTempFile()
 	defer os.Remove(path)
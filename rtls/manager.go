@@ -0,0 +1,501 @@
+package rtls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoCertificate is returned when a certificate rotation does not include
+	// both a certificate and a key.
+	ErrNoCertificate = errors.New("certificate and key must both be set")
+
+	// ErrCANotStaged is returned when a certificate is activated that is not
+	// signed by a trusted CA.
+	ErrCANotStaged = errors.New("certificate not signed by a trusted CA")
+)
+
+// CertManager holds the certificate a node presents, and the CA certificates it
+// trusts, for TLS connections between nodes. Both can be changed while the node
+// is running, allowing certificates to be rotated without a restart.
+//
+// Rotation takes place in two steps. First new CA certificates are staged, and
+// are trusted alongside any existing CA certificates. Once every node trusts the
+// new CAs a new certificate, signed by one of them, is activated. After an overlap
+// period the CA certificates trusted before the rotation are retired.
+//
+// If the CertManager was created with file paths, every change is also written
+// to those files, so the node uses the new certificates after a restart. The CA
+// certificates staged in the current rotation, and any pending retirement, are
+// written alongside the CA file, so a retirement takes place when due even if the
+// node restarts during the overlap period.
+type CertManager struct {
+	certFile   string
+	keyFile    string
+	caCertFile string
+	noverify   bool
+	mutual     bool
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	caPEMs   [][]byte // Trusted CA certificates, one per PEM block, oldest first.
+	staged   [][]byte // CA certificates staged in the current rotation.
+	caPool   *x509.CertPool
+	retireTm *time.Timer
+	retireAt time.Time
+}
+
+// rotationState is the state of a rotation in progress, written alongside the
+// CA file.
+type rotationState struct {
+	Staged   []string  `json:"staged"`
+	RetireAt time.Time `json:"retire_at,omitempty"`
+}
+
+// NewCertManager returns a CertManager, with the certificate and CA certificates
+// loaded from the given files. If noverify is true, the certificate presented by
+// remote servers is not verified. If mutual is true, the certificate presented by
+// remote clients must be signed by a trusted CA.
+func NewCertManager(certFile, keyFile, caCertFile string, noverify, mutual bool) (*CertManager, error) {
+	m := &CertManager{
+		certFile:   certFile,
+		keyFile:    keyFile,
+		caCertFile: caCertFile,
+		noverify:   noverify,
+		mutual:     mutual,
+	}
+
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		m.cert = &cert
+	}
+
+	if caCertFile != "" {
+		asn1Data, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		m.caPEMs = splitPEM(asn1Data)
+		if err := m.setPool(); err != nil {
+			return nil, fmt.Errorf("failed to load CA certificate(s) in %q: %s", caCertFile, err.Error())
+		}
+		if err := m.loadRotation(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Config returns a TLS configuration for use by a system that acts as both a
+// client and a server. The configuration always uses the current certificate
+// and CA certificates of the CertManager.
+func (m *CertManager) Config() *tls.Config {
+	// Server certificates are verified by verifyServer, since the CA
+	// certificates may change after the configuration is created.
+	config := createBaseTLSConfig(true)
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return m.certificate()
+	}
+	config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return m.certificate()
+	}
+	config.VerifyConnection = m.verifyServer
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		sc := config.Clone()
+		sc.GetConfigForClient = nil
+		sc.VerifyConnection = nil
+		if m.mutual {
+			sc.ClientAuth = tls.RequireAndVerifyClientCert
+			m.mu.RLock()
+			sc.ClientCAs = m.caPool
+			m.mu.RUnlock()
+		}
+		return sc, nil
+	}
+	return config
+}
+
+// StageCA adds the given PEM-encoded CA certificates to those trusted, and to
+// those kept when CA certificates are next retired.
+func (m *CertManager) StageCA(caCertPEM []byte) error {
+	if len(caCertPEM) == 0 {
+		return nil
+	}
+	blocks := splitPEM(caCertPEM)
+	for _, b := range blocks {
+		if ok := x509.NewCertPool().AppendCertsFromPEM(b); !ok {
+			return fmt.Errorf("failed to parse CA certificate(s)")
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range blocks {
+		if !containsPEM(m.caPEMs, b) {
+			m.caPEMs = append(m.caPEMs, b)
+		}
+		if !containsPEM(m.staged, b) {
+			m.staged = append(m.staged, b)
+		}
+	}
+	if err := m.setPool(); err != nil {
+		return err
+	}
+	if err := m.writeCAFile(); err != nil {
+		return err
+	}
+	return m.writeRotation()
+}
+
+// ActivateCert sets the certificate presented by this node to the given PEM-encoded
+// certificate and key. Once overlap has elapsed all CA certificates, except those
+// staged in this rotation, are retired. If overlap is zero, or no CA certificates
+// have been staged, no CA certificates are retired.
+func (m *CertManager) ActivateCert(certPEM, keyPEM []byte, overlap time.Duration) error {
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return ErrNoCertificate
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.noverify && m.caPool != nil {
+		if err := verifyLeaf(&cert, m.caPool); err != nil {
+			return fmt.Errorf("%s: %s", ErrCANotStaged.Error(), err.Error())
+		}
+	}
+	if m.certFile != "" && m.keyFile != "" {
+		if err := writeKeyPair(m.certFile, m.keyFile, certPEM, keyPEM); err != nil {
+			return err
+		}
+	}
+	m.cert = &cert
+
+	m.stopRetire()
+	if overlap > 0 && len(m.staged) > 0 {
+		m.scheduleRetire(time.Now().Add(overlap))
+	}
+	return m.writeRotation()
+}
+
+// RetireCAs stops trusting all CA certificates, except those staged in the
+// current rotation. If no CA certificates have been staged, none are retired.
+func (m *CertManager) RetireCAs() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopRetire()
+	if len(m.staged) == 0 {
+		return m.writeRotation()
+	}
+	var keep [][]byte
+	for _, p := range m.caPEMs {
+		if containsPEM(m.staged, p) {
+			keep = append(keep, p)
+		}
+	}
+	m.caPEMs = keep
+	m.staged = nil
+	if err := m.setPool(); err != nil {
+		return err
+	}
+	if err := m.writeCAFile(); err != nil {
+		return err
+	}
+	return m.writeRotation()
+}
+
+// Close stops any pending retirement of CA certificates. A retirement written
+// to disk still takes place when the node next starts.
+func (m *CertManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopRetire()
+}
+
+// Stats returns status information about the certificates in use.
+func (m *CertManager) Stats() (map[string]interface{}, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := map[string]interface{}{
+		"num_trusted_ca_certs": len(m.caPEMs),
+		"num_staged_ca_certs":  len(m.staged),
+		"retire_pending":       m.retireTm != nil,
+	}
+	if m.retireTm != nil {
+		st["retire_at"] = m.retireAt.Format(time.RFC3339)
+	}
+	if m.cert != nil && len(m.cert.Certificate) > 0 {
+		leaf, err := x509.ParseCertificate(m.cert.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+		st["cert_subject"] = leaf.Subject.String()
+		st["cert_not_after"] = leaf.NotAfter.Format(time.RFC3339)
+	}
+	return st, nil
+}
+
+func (m *CertManager) certificate() (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cert == nil {
+		// An empty certificate means none is presented.
+		return &tls.Certificate{}, nil
+	}
+	return m.cert, nil
+}
+
+// verifyServer verifies the certificate chain presented by a server, using the
+// CA certificates trusted at the time of the connection. If no CA certificates
+// are set, the system CA certificates are used.
+func (m *CertManager) verifyServer(cs tls.ConnectionState) error {
+	if m.noverify {
+		return nil
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no certificate presented by server")
+	}
+	m.mu.RLock()
+	pool := m.caPool
+	m.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// setPool rebuilds the CA pool from the trusted CA certificates. It must be
+// called with the lock held.
+func (m *CertManager) setPool() error {
+	pool := x509.NewCertPool()
+	for _, p := range m.caPEMs {
+		if ok := pool.AppendCertsFromPEM(p); !ok {
+			return errors.New("failed to parse CA certificate(s)")
+		}
+	}
+	m.caPool = pool
+	return nil
+}
+
+// writeCAFile writes all trusted CA certificates to the CA file, if one is set.
+// It must be called with the lock held.
+func (m *CertManager) writeCAFile() error {
+	if m.caCertFile == "" {
+		return nil
+	}
+	return writeFileAtomic(m.caCertFile, bytes.Join(m.caPEMs, nil), 0644)
+}
+
+// rotationFile returns the path of the file recording the state of the
+// current rotation.
+func (m *CertManager) rotationFile() string {
+	return m.caCertFile + ".rotation"
+}
+
+// loadRotation restores the state of any rotation in progress when the node
+// stopped. If a retirement is already due, the CA certificates are retired
+// immediately.
+func (m *CertManager) loadRotation() error {
+	b, err := os.ReadFile(m.rotationFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var rs rotationState
+	if err := json.Unmarshal(b, &rs); err != nil {
+		return fmt.Errorf("failed to parse CA rotation state in %q: %s", m.rotationFile(), err.Error())
+	}
+	for _, p := range rs.Staged {
+		if containsPEM(m.caPEMs, []byte(p)) {
+			m.staged = append(m.staged, []byte(p))
+		}
+	}
+	if rs.RetireAt.IsZero() {
+		return nil
+	}
+	if !time.Now().Before(rs.RetireAt) {
+		return m.RetireCAs()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduleRetire(rs.RetireAt)
+	return nil
+}
+
+// writeRotation writes the state of the current rotation alongside the CA file,
+// if one is set. If no rotation is in progress, the file is removed. It must
+// be called with the lock held.
+func (m *CertManager) writeRotation() error {
+	if m.caCertFile == "" {
+		return nil
+	}
+	if len(m.staged) == 0 {
+		if err := os.Remove(m.rotationFile()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	rs := rotationState{
+		RetireAt: m.retireAt,
+	}
+	for _, p := range m.staged {
+		rs.Staged = append(rs.Staged, string(p))
+	}
+	b, err := json.Marshal(rs)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.rotationFile(), b, 0644)
+}
+
+// scheduleRetire arranges for CA certificates to be retired at the given time.
+// It must be called with the lock held.
+func (m *CertManager) scheduleRetire(at time.Time) {
+	m.retireAt = at
+	m.retireTm = time.AfterFunc(time.Until(at), func() {
+		m.RetireCAs()
+	})
+}
+
+// stopRetire stops any pending retirement timer. It must be called with the
+// lock held.
+func (m *CertManager) stopRetire() {
+	if m.retireTm != nil {
+		m.retireTm.Stop()
+		m.retireTm = nil
+	}
+	m.retireAt = time.Time{}
+}
+
+// containsPEM returns whether p is one of pems.
+func containsPEM(pems [][]byte, p []byte) bool {
+	for _, q := range pems {
+		if bytes.Equal(q, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPEM splits PEM-encoded data into its individual blocks, so that CA
+// certificates written by writeCAFile can be retired independently.
+func splitPEM(b []byte) [][]byte {
+	var blocks [][]byte
+	for {
+		var p *pem.Block
+		p, b = pem.Decode(b)
+		if p == nil {
+			break
+		}
+		blocks = append(blocks, pem.EncodeToMemory(p))
+	}
+	if len(blocks) == 0 {
+		// Let setPool report the invalid data.
+		return [][]byte{b}
+	}
+	return blocks
+}
+
+func verifyLeaf(cert *tls.Certificate, pool *x509.CertPool) error {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range cert.Certificate[1:] {
+		ic, err := x509.ParseCertificate(c)
+		if err != nil {
+			return err
+		}
+		intermediates.AddCert(ic)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// writeKeyPair writes a certificate and its key to the given files. Both are
+// written in full to temporary files before either file is replaced, so that
+// a failure part way through leaves the existing pair in place.
+func writeKeyPair(certFile, keyFile string, certPEM, keyPEM []byte) error {
+	certTmp, err := writeTempFile(certFile, certPEM, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(certTmp)
+	keyTmp, err := writeTempFile(keyFile, keyPEM, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(keyTmp)
+
+	if err := os.Rename(keyTmp, keyFile); err != nil {
+		return err
+	}
+	return os.Rename(certTmp, certFile)
+}
+
+func writeFileAtomic(path string, b []byte, perm os.FileMode) error {
+	tmp, err := writeTempFile(path, b, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Rename(tmp, path)
+}
+
+// writeTempFile writes b to a new temporary file, synced to disk, in the same
+// directory as path, and returns the name of the temporary file.
+func writeTempFile(path string, b []byte, perm os.FileMode) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,376 @@
+package rtls
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_CertManagerRotate(t *testing.T) {
+	ca1, ca1Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 1"})
+	srvMgr := mustNewCertManager(t, ca1, ca1Key, true)
+	cltMgr := mustNewCertManager(t, ca1, ca1Key, false)
+	if err := handshake(srvMgr, cltMgr); err != nil {
+		t.Fatalf("handshake with original certificates failed: %s", err.Error())
+	}
+
+	// Stage the new CA on both nodes, original certificates should still work.
+	ca2, ca2Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 2"})
+	for _, m := range []*CertManager{srvMgr, cltMgr} {
+		if err := m.StageCA(mustEncodeCert(ca2)); err != nil {
+			t.Fatalf("failed to stage CA: %s", err.Error())
+		}
+	}
+	if err := handshake(srvMgr, cltMgr); err != nil {
+		t.Fatalf("handshake after staging CA failed: %s", err.Error())
+	}
+
+	// Activate new certificates one node at a time.
+	certPEM, keyPEM := mustGenerateCert(ca2, ca2Key)
+	if err := srvMgr.ActivateCert(certPEM, keyPEM, 0); err != nil {
+		t.Fatalf("failed to activate certificate: %s", err.Error())
+	}
+	if err := handshake(srvMgr, cltMgr); err != nil {
+		t.Fatalf("handshake after activating server certificate failed: %s", err.Error())
+	}
+	certPEM, keyPEM = mustGenerateCert(ca2, ca2Key)
+	if err := cltMgr.ActivateCert(certPEM, keyPEM, 0); err != nil {
+		t.Fatalf("failed to activate certificate: %s", err.Error())
+	}
+	if err := handshake(srvMgr, cltMgr); err != nil {
+		t.Fatalf("handshake after activating client certificate failed: %s", err.Error())
+	}
+
+	// Retire the original CA, a node with an original certificate should no
+	// longer be able to connect.
+	oldMgr := mustNewCertManager(t, ca1, ca1Key, false)
+	if err := oldMgr.StageCA(mustEncodeCert(ca2)); err != nil {
+		t.Fatalf("failed to stage CA: %s", err.Error())
+	}
+	if err := handshake(srvMgr, oldMgr); err != nil {
+		t.Fatalf("handshake with original certificate failed before retiring CA: %s", err.Error())
+	}
+	for _, m := range []*CertManager{srvMgr, cltMgr} {
+		if err := m.RetireCAs(); err != nil {
+			t.Fatalf("failed to retire CAs: %s", err.Error())
+		}
+	}
+	if err := handshake(srvMgr, cltMgr); err != nil {
+		t.Fatalf("handshake after retiring CA failed: %s", err.Error())
+	}
+	if err := handshake(srvMgr, oldMgr); err == nil {
+		t.Fatalf("handshake with original certificate succeeded after retiring CA")
+	}
+}
+
+func Test_CertManagerActivateNotTrusted(t *testing.T) {
+	ca1, ca1Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 1"})
+	m := mustNewCertManager(t, ca1, ca1Key, false)
+
+	ca2, ca2Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 2"})
+	certPEM, keyPEM := mustGenerateCert(ca2, ca2Key)
+	if err := m.ActivateCert(certPEM, keyPEM, 0); err == nil {
+		t.Fatalf("activated certificate signed by CA which was not staged")
+	}
+	if err := m.ActivateCert(nil, keyPEM, 0); err != ErrNoCertificate {
+		t.Fatalf("expected ErrNoCertificate, got %v", err)
+	}
+}
+
+func Test_CertManagerPersist(t *testing.T) {
+	ca1, ca1Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 1"})
+	m := mustNewCertManager(t, ca1, ca1Key, false)
+
+	ca2, ca2Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 2"})
+	if err := m.StageCA(mustEncodeCert(ca2)); err != nil {
+		t.Fatalf("failed to stage CA: %s", err.Error())
+	}
+	certPEM, keyPEM := mustGenerateCert(ca2, ca2Key)
+	if err := m.ActivateCert(certPEM, keyPEM, 0); err != nil {
+		t.Fatalf("failed to activate certificate: %s", err.Error())
+	}
+
+	// A restarted node should use the new certificate and trust both CAs.
+	m2, err := NewCertManager(m.certFile, m.keyFile, m.caCertFile, false, false)
+	if err != nil {
+		t.Fatalf("failed to create certificate manager from rotated files: %s", err.Error())
+	}
+	st, err := m2.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %s", err.Error())
+	}
+	if got, exp := st["num_trusted_ca_certs"], 2; got != exp {
+		t.Fatalf("wrong number of trusted CAs, got %v, exp %v", got, exp)
+	}
+	if got, exp := st["cert_subject"], "CN=rqlite"; got != exp {
+		t.Fatalf("wrong certificate subject, got %v, exp %v", got, exp)
+	}
+	if err := verifyLeaf(m2.cert, m2.caPool); err != nil {
+		t.Fatalf("rotated certificate not trusted after restart: %s", err.Error())
+	}
+	if err := m2.RetireCAs(); err != nil {
+		t.Fatalf("failed to retire CAs: %s", err.Error())
+	}
+	if err := verifyLeaf(m2.cert, m2.caPool); err != nil {
+		t.Fatalf("rotated certificate not trusted after retiring CAs: %s", err.Error())
+	}
+
+	b, err := os.ReadFile(m.keyFile)
+	if err != nil {
+		t.Fatalf("failed to read key file: %s", err.Error())
+	}
+	if string(b) != string(keyPEM) {
+		t.Fatalf("key file does not contain rotated key")
+	}
+}
+
+func Test_CertManagerRetireOverlap(t *testing.T) {
+	ca1, ca1Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 1"})
+	m := mustNewCertManager(t, ca1, ca1Key, false)
+	defer m.Close()
+
+	ca2, ca2Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 2"})
+	if err := m.StageCA(mustEncodeCert(ca2)); err != nil {
+		t.Fatalf("failed to stage CA: %s", err.Error())
+	}
+	certPEM, keyPEM := mustGenerateCert(ca2, ca2Key)
+	if err := m.ActivateCert(certPEM, keyPEM, 100*time.Millisecond); err != nil {
+		t.Fatalf("failed to activate certificate: %s", err.Error())
+	}
+	st, _ := m.Stats()
+	if got, exp := st["num_trusted_ca_certs"], 2; got != exp {
+		t.Fatalf("wrong number of trusted CAs, got %v, exp %v", got, exp)
+	}
+
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			st, _ := m.Stats()
+			if st["num_trusted_ca_certs"] == 1 {
+				if st["retire_pending"] != false {
+					t.Fatalf("retirement still pending after CA retired")
+				}
+				return
+			}
+		case <-timer.C:
+			t.Fatalf("timed out waiting for CA to be retired")
+		}
+	}
+}
+
+func Test_CertManagerRetireRestart(t *testing.T) {
+	ca1, ca1Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 1"})
+	m := mustNewCertManager(t, ca1, ca1Key, false)
+
+	// Stage a bundle of two CA certificates, both of which must be kept.
+	ca2, ca2Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 2"})
+	ca3, _ := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 3"})
+	if err := m.StageCA(append(mustEncodeCert(ca2), mustEncodeCert(ca3)...)); err != nil {
+		t.Fatalf("failed to stage CA: %s", err.Error())
+	}
+	certPEM, keyPEM := mustGenerateCert(ca2, ca2Key)
+	if err := m.ActivateCert(certPEM, keyPEM, time.Hour); err != nil {
+		t.Fatalf("failed to activate certificate: %s", err.Error())
+	}
+	m.Close()
+
+	// A node restarted during the overlap period should still retire the CA.
+	m2, err := NewCertManager(m.certFile, m.keyFile, m.caCertFile, false, false)
+	if err != nil {
+		t.Fatalf("failed to create certificate manager: %s", err.Error())
+	}
+	st, _ := m2.Stats()
+	if st["retire_pending"] != true {
+		t.Fatalf("retirement not pending after restart")
+	}
+	if got, exp := st["num_trusted_ca_certs"], 3; got != exp {
+		t.Fatalf("wrong number of trusted CAs, got %v, exp %v", got, exp)
+	}
+	if got, exp := st["num_staged_ca_certs"], 2; got != exp {
+		t.Fatalf("wrong number of staged CAs, got %v, exp %v", got, exp)
+	}
+	m2.Close()
+
+	// A node restarted after the overlap period should retire the CA at once,
+	// keeping the whole staged bundle.
+	b, err := os.ReadFile(m.rotationFile())
+	if err != nil {
+		t.Fatalf("failed to read rotation file: %s", err.Error())
+	}
+	var rs rotationState
+	if err := json.Unmarshal(b, &rs); err != nil {
+		t.Fatalf("failed to parse rotation file: %s", err.Error())
+	}
+	rs.RetireAt = time.Now().Add(-time.Minute)
+	b, err = json.Marshal(rs)
+	if err != nil {
+		t.Fatalf("failed to marshal rotation state: %s", err.Error())
+	}
+	if err := os.WriteFile(m.rotationFile(), b, 0644); err != nil {
+		t.Fatalf("failed to write rotation file: %s", err.Error())
+	}
+	m3, err := NewCertManager(m.certFile, m.keyFile, m.caCertFile, false, false)
+	if err != nil {
+		t.Fatalf("failed to create certificate manager: %s", err.Error())
+	}
+	st, _ = m3.Stats()
+	if st["retire_pending"] != false {
+		t.Fatalf("retirement still pending after deadline passed")
+	}
+	if got, exp := st["num_trusted_ca_certs"], 2; got != exp {
+		t.Fatalf("wrong number of trusted CAs, got %v, exp %v", got, exp)
+	}
+	if _, err := os.Stat(m.rotationFile()); !os.IsNotExist(err) {
+		t.Fatalf("rotation file still exists after CA retired")
+	}
+	if err := verifyLeaf(m3.cert, m3.caPool); err != nil {
+		t.Fatalf("rotated certificate not trusted after retiring CAs: %s", err.Error())
+	}
+	caPEM, err := os.ReadFile(m.caCertFile)
+	if err != nil {
+		t.Fatalf("failed to read CA file: %s", err.Error())
+	}
+	if exp, got := string(mustEncodeCert(ca2))+string(mustEncodeCert(ca3)), string(caPEM); exp != got {
+		t.Fatalf("CA file does not contain staged bundle after retiring CAs")
+	}
+}
+
+func Test_CertManagerRetireNothingStaged(t *testing.T) {
+	// The CA file holds two certificates, neither of which may be retired
+	// by an activation when no CA has been staged.
+	ca1, ca1Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 1"})
+	ca2, _ := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 2"})
+	caPEM := append(mustEncodeCert(ca2), mustEncodeCert(ca1)...)
+	certPEM, keyPEM := mustGenerateCert(ca1, ca1Key)
+	m, err := NewCertManager(mustWriteTempFile(t, certPEM), mustWriteTempFile(t, keyPEM),
+		mustWriteTempFile(t, caPEM), false, false)
+	if err != nil {
+		t.Fatalf("failed to create certificate manager: %s", err.Error())
+	}
+	defer m.Close()
+
+	certPEM, keyPEM = mustGenerateCert(ca1, ca1Key)
+	if err := m.ActivateCert(certPEM, keyPEM, time.Millisecond); err != nil {
+		t.Fatalf("failed to activate certificate: %s", err.Error())
+	}
+	if err := m.RetireCAs(); err != nil {
+		t.Fatalf("failed to retire CAs: %s", err.Error())
+	}
+	st, _ := m.Stats()
+	if st["retire_pending"] != false {
+		t.Fatalf("retirement pending with no CA staged")
+	}
+	if got, exp := st["num_trusted_ca_certs"], 2; got != exp {
+		t.Fatalf("wrong number of trusted CAs, got %v, exp %v", got, exp)
+	}
+	b, err := os.ReadFile(m.caCertFile)
+	if err != nil {
+		t.Fatalf("failed to read CA file: %s", err.Error())
+	}
+	if string(b) != string(caPEM) {
+		t.Fatalf("CA file changed with no CA staged")
+	}
+}
+
+func Test_CertManagerActivateWriteFail(t *testing.T) {
+	ca1, ca1Key := mustGenerateCACert(pkix.Name{CommonName: "rqlite CA 1"})
+	m := mustNewCertManager(t, ca1, ca1Key, false)
+	origCert := m.cert
+	origCertPEM, err := os.ReadFile(m.certFile)
+	if err != nil {
+		t.Fatalf("failed to read certificate file: %s", err.Error())
+	}
+
+	// Writing the key fails, so neither the certificate in use nor the
+	// certificate file should change.
+	m.keyFile = filepath.Join(t.TempDir(), "missing", "key.pem")
+	certPEM, keyPEM := mustGenerateCert(ca1, ca1Key)
+	if err := m.ActivateCert(certPEM, keyPEM, 0); err == nil {
+		t.Fatalf("activated certificate when key could not be written")
+	}
+	if m.cert != origCert {
+		t.Fatalf("certificate in use changed after failed activation")
+	}
+	b, err := os.ReadFile(m.certFile)
+	if err != nil {
+		t.Fatalf("failed to read certificate file: %s", err.Error())
+	}
+	if string(b) != string(origCertPEM) {
+		t.Fatalf("certificate file changed after failed activation")
+	}
+}
+
+// handshake performs a TLS handshake between a server using the first
+// CertManager and a client using the second, returning any error.
+func handshake(srv, clt *CertManager) error {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srv.Config())
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	srvErrCh := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			srvErrCh <- err
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.(*tls.Conn).Handshake(); err != nil {
+			srvErrCh <- err
+			return
+		}
+		_, err = conn.Write([]byte{1})
+		srvErrCh <- err
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), clt.Config())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return err
+	}
+	return <-srvErrCh
+}
+
+// mustNewCertManager returns a CertManager, backed by temporary files, with a
+// certificate signed by the given CA.
+func mustNewCertManager(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey, mutual bool) *CertManager {
+	certPEM, keyPEM := mustGenerateCert(ca, caKey)
+	m, err := NewCertManager(mustWriteTempFile(t, certPEM), mustWriteTempFile(t, keyPEM),
+		mustWriteTempFile(t, mustEncodeCert(ca)), false, mutual)
+	if err != nil {
+		t.Fatalf("failed to create certificate manager: %s", err.Error())
+	}
+	return m
+}
+
+func mustGenerateCert(ca *x509.Certificate, caKey *rsa.PrivateKey) ([]byte, []byte) {
+	certPEM, keyPEM, err := GenerateCertIPSAN(pkix.Name{CommonName: "rqlite"}, time.Hour, 2048,
+		ca, caKey, net.ParseIP("127.0.0.1"))
+	if err != nil {
+		panic(err)
+	}
+	return certPEM, keyPEM
+}
+
+func mustEncodeCert(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rqlite/rqlite/command"
+	"github.com/rqlite/sql"
+)
+
+// coalescedExecute is an Execute request waiting to be merged with others.
+type coalescedExecute struct {
+	er     *command.ExecuteRequest
+	respCh chan *fsmExecuteResponse
+}
+
+// coalescer merges concurrent Execute requests into a single request, so that
+// many small writes are committed as one Raft log entry. Each caller receives
+// only the results for the statements in its own request.
+//
+// Only requests which are not executed within a transaction, and which contain no
+// transaction control statements, are merged. Outside of a transaction each
+// statement is executed independently, so a failing statement in one request
+// does not affect the statements of any other request in the same entry.
+type coalescer struct {
+	batchSz int
+	timeout time.Duration
+	applyFn func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error)
+
+	reqCh   chan *coalescedExecute
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+// newCoalescer returns a coalescer which merges up to batchSz requests, waiting
+// at most timeout for more requests to arrive once a request is received. If
+// timeout is zero, only requests already waiting are merged. Merged requests are
+// passed to applyFn.
+func newCoalescer(batchSz int, timeout time.Duration,
+	applyFn func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error)) *coalescer {
+	c := &coalescer{
+		batchSz: batchSz,
+		timeout: timeout,
+		applyFn: applyFn,
+		reqCh:   make(chan *coalescedExecute),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Execute submits the request for merging, and returns the results of its
+// statements once the merged request has been applied.
+func (c *coalescer) Execute(er *command.ExecuteRequest) ([]*command.ExecuteResult, error) {
+	req := &coalescedExecute{
+		er:     er,
+		respCh: make(chan *fsmExecuteResponse, 1),
+	}
+	select {
+	case c.reqCh <- req:
+	case <-c.closeCh:
+		return nil, ErrNotOpen
+	}
+	resp := <-req.respCh
+	return resp.results, resp.error
+}
+
+// Close stops the coalescer, waiting for any merged request being applied.
+func (c *coalescer) Close() {
+	close(c.closeCh)
+	<-c.doneCh
+}
+
+func (c *coalescer) run() {
+	defer close(c.doneCh)
+	for {
+		select {
+		case req := <-c.reqCh:
+			c.apply(c.gather(req))
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+// gather returns a batch of requests, starting with the given request.
+func (c *coalescer) gather(req *coalescedExecute) []*coalescedExecute {
+	batch := []*coalescedExecute{req}
+	var timeoutCh <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	for len(batch) < c.batchSz {
+		if timeoutCh == nil {
+			select {
+			case r := <-c.reqCh:
+				batch = append(batch, r)
+			default:
+				return batch
+			}
+			continue
+		}
+
+		select {
+		case r := <-c.reqCh:
+			batch = append(batch, r)
+		case <-timeoutCh:
+			return batch
+		case <-c.closeCh:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (c *coalescer) apply(batch []*coalescedExecute) {
+	if len(batch) == 1 {
+		results, err := c.applyFn(batch[0].er)
+		batch[0].respCh <- &fsmExecuteResponse{results: results, error: err}
+		return
+	}
+
+	stats.Add(numCoalescedEntries, 1)
+	stats.Add(numCoalescedExecutes, int64(len(batch)))
+	merged := &command.ExecuteRequest{
+		Request: &command.Request{},
+	}
+	for _, r := range batch {
+		merged.Timings = merged.Timings || r.er.Timings
+		merged.Request.Statements = append(merged.Request.Statements, r.er.Request.Statements...)
+	}
+
+	results, err := c.applyFn(merged)
+	if err != nil {
+		for _, r := range batch {
+			r.respCh <- &fsmExecuteResponse{error: err}
+		}
+		return
+	}
+
+	// Return to each request the results for its statements. Each result
+	// records the index of its statement within the merged request.
+	rs := make([][]*command.ExecuteResult, len(batch))
+	start := 0
+	i := 0
+	for _, res := range results {
+		for i < len(batch) && int(res.StmtIndex) >= start+len(batch[i].er.Request.Statements) {
+			start += len(batch[i].er.Request.Statements)
+			i++
+		}
+		if i == len(batch) {
+			break
+		}
+		res.StmtIndex -= int64(start)
+		if !batch[i].er.Timings {
+			res.Time = 0
+		}
+		rs[i] = append(rs[i], res)
+	}
+	for i, r := range batch {
+		r.respCh <- &fsmExecuteResponse{results: rs[i]}
+	}
+}
+
+// canCoalesce returns whether the given Execute request can be merged with others.
+// Requests containing transaction control statements are not merged, since the
+// transaction would include the statements of other requests.
+func canCoalesce(er *command.ExecuteRequest) bool {
+	if er.Request == nil || er.Request.Transaction {
+		return false
+	}
+	for _, stmt := range er.Request.Statements {
+		if !isCoalescable(stmt.Sql) {
+			return false
+		}
+	}
+	return true
+}
+
+// isCoalescable returns whether the given SQL can be executed alongside the
+// statements of other requests. SQL which cannot be parsed is not coalesced,
+// since it may contain transaction control statements.
+func isCoalescable(s string) bool {
+	p := sql.NewParser(strings.NewReader(s))
+	for {
+		stmt, err := p.ParseStatement()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+		switch stmt.(type) {
+		case *sql.BeginStatement, *sql.CommitStatement, *sql.RollbackStatement,
+			*sql.SavepointStatement, *sql.ReleaseStatement:
+			return false
+		}
+	}
+}
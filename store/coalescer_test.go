@@ -0,0 +1,230 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rqlite/rqlite/command"
+)
+
+func Test_CoalescerSingle(t *testing.T) {
+	c := newCoalescer(10, 0, func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error) {
+		if len(er.Request.Statements) != 2 {
+			t.Errorf("wrong number of statements, exp 2, got %d", len(er.Request.Statements))
+		}
+		return mockExecuteResults(er, 1), nil
+	})
+	defer c.Close()
+
+	results, err := c.Execute(executeRequestFromStrings([]string{"stmt1", "stmt2"}, true, false))
+	if err != nil {
+		t.Fatalf("failed to execute: %s", err.Error())
+	}
+	if exp, got := `[{"last_insert_id":1,"time":1},{"last_insert_id":2,"time":1}]`, asJSON(results); exp != got {
+		t.Fatalf("unexpected results\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+func Test_CoalescerMerge(t *testing.T) {
+	var mu sync.Mutex
+	var applied []*command.ExecuteRequest
+	releaseCh := make(chan struct{})
+
+	c := newCoalescer(10, 0, func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error) {
+		mu.Lock()
+		applied = append(applied, er)
+		n := len(applied)
+		mu.Unlock()
+		if n == 1 {
+			// Block the first request, so others queue up behind it.
+			<-releaseCh
+		}
+		return mockExecuteResults(er, 1), nil
+	})
+	defer c.Close()
+
+	go c.Execute(executeRequestFromString("first", false, false))
+	waitForApplied := func(n int) {
+		for {
+			mu.Lock()
+			l := len(applied)
+			mu.Unlock()
+			if l >= n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForApplied(1)
+
+	reqs := []*command.ExecuteRequest{
+		executeRequestFromStrings([]string{"a1", "a2"}, false, false),
+		executeRequestFromStrings([]string{"b1", "", "b2", "b3"}, true, false),
+		executeRequestFromStrings([]string{"c1"}, false, false),
+	}
+	var wg sync.WaitGroup
+	resultsCh := make(chan struct {
+		er      *command.ExecuteRequest
+		results []*command.ExecuteResult
+	}, len(reqs))
+	for _, er := range reqs {
+		wg.Add(1)
+		go func(er *command.ExecuteRequest) {
+			defer wg.Done()
+			results, err := c.Execute(er)
+			if err != nil {
+				t.Errorf("failed to execute: %s", err.Error())
+			}
+			resultsCh <- struct {
+				er      *command.ExecuteRequest
+				results []*command.ExecuteResult
+			}{er, results}
+		}(er)
+	}
+
+	// Wait for all requests to be waiting on the coalescer, then release.
+	time.Sleep(100 * time.Millisecond)
+	close(releaseCh)
+	wg.Wait()
+	close(resultsCh)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(applied) != 2 {
+		t.Fatalf("expected requests to be merged into 2 applies, got %d", len(applied))
+	}
+	merged := applied[1]
+	if len(merged.Request.Statements) != 7 {
+		t.Fatalf("wrong number of merged statements, exp 7, got %d", len(merged.Request.Statements))
+	}
+	if merged.Request.Transaction {
+		t.Fatalf("merged request has transaction set")
+	}
+	if !merged.Timings {
+		t.Fatalf("merged request does not have timings set")
+	}
+
+	// Each request must get the results of its own statements, with timings only
+	// if requested. Results are numbered in the order statements were merged.
+	ids := map[string]int64{}
+	for i, stmt := range merged.Request.Statements {
+		ids[stmt.Sql] = int64(i + 1)
+	}
+	numResponses := 0
+	for r := range resultsCh {
+		numResponses++
+		var nonEmpty []string
+		var idxs []int64
+		for i, stmt := range r.er.Request.Statements {
+			if stmt.Sql != "" {
+				nonEmpty = append(nonEmpty, stmt.Sql)
+				idxs = append(idxs, int64(i))
+			}
+		}
+		if len(r.results) != len(nonEmpty) {
+			t.Fatalf("wrong number of results for %v, exp %d, got %d", nonEmpty, len(nonEmpty), len(r.results))
+		}
+		for i, res := range r.results {
+			if exp, got := ids[nonEmpty[i]], res.LastInsertId; exp != got {
+				t.Fatalf("wrong result for statement %s, exp %d, got %d", nonEmpty[i], exp, got)
+			}
+			if exp, got := idxs[i], res.StmtIndex; exp != got {
+				t.Fatalf("wrong statement index for statement %s, exp %d, got %d", nonEmpty[i], exp, got)
+			}
+			if r.er.Timings != (res.Time != 0) {
+				t.Fatalf("wrong timings for statement %s, got %v", nonEmpty[i], res.Time)
+			}
+		}
+	}
+	if numResponses != len(reqs) {
+		t.Fatalf("wrong number of responses, exp %d, got %d", len(reqs), numResponses)
+	}
+}
+
+func Test_CoalescerError(t *testing.T) {
+	releaseCh := make(chan struct{})
+	var once sync.Once
+	c := newCoalescer(10, 100*time.Millisecond, func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error) {
+		once.Do(func() { <-releaseCh })
+		return nil, ErrNotLeader
+	})
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Execute(executeRequestFromString("stmt", false, false)); err != ErrNotLeader {
+				t.Errorf("expected ErrNotLeader, got %v", err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(releaseCh)
+	wg.Wait()
+}
+
+func Test_CoalescerClose(t *testing.T) {
+	c := newCoalescer(10, 0, func(er *command.ExecuteRequest) ([]*command.ExecuteResult, error) {
+		return nil, errors.New("should not be called")
+	})
+	c.Close()
+	if _, err := c.Execute(executeRequestFromString("stmt", false, false)); err != ErrNotOpen {
+		t.Fatalf("expected ErrNotOpen, got %v", err)
+	}
+}
+
+func Test_CanCoalesce(t *testing.T) {
+	if !canCoalesce(executeRequestFromString("INSERT INTO foo(name) VALUES('fiona')", false, false)) {
+		t.Fatalf("request without transaction cannot be coalesced")
+	}
+	if !canCoalesce(executeRequestFromStrings([]string{"INSERT INTO foo(name) VALUES('fiona')", ""}, false, false)) {
+		t.Fatalf("request with empty statement cannot be coalesced")
+	}
+	if canCoalesce(executeRequestFromString("INSERT INTO foo(name) VALUES('fiona')", false, true)) {
+		t.Fatalf("request with transaction can be coalesced")
+	}
+	if canCoalesce(&command.ExecuteRequest{}) {
+		t.Fatalf("request with no statements can be coalesced")
+	}
+
+	for _, s := range []string{
+		"BEGIN",
+		"BEGIN IMMEDIATE TRANSACTION",
+		"COMMIT",
+		"END",
+		"ROLLBACK",
+		"SAVEPOINT sp",
+		"RELEASE sp",
+		"INSERT INTO foo(name) VALUES('fiona'); BEGIN",
+		"not valid SQL",
+	} {
+		er := executeRequestFromStrings([]string{"INSERT INTO foo(name) VALUES('fiona')", s}, false, false)
+		if canCoalesce(er) {
+			t.Fatalf("request containing %q can be coalesced", s)
+		}
+	}
+}
+
+// mockExecuteResults returns a result for each non-empty statement in the request,
+// with LastInsertId set to one more than the index of its statement.
+func mockExecuteResults(er *command.ExecuteRequest, tm float64) []*command.ExecuteResult {
+	var results []*command.ExecuteResult
+	for i, stmt := range er.Request.Statements {
+		if stmt.Sql == "" {
+			continue
+		}
+		res := &command.ExecuteResult{
+			LastInsertId: int64(i + 1),
+			StmtIndex:    int64(i),
+		}
+		if er.Timings {
+			res.Time = tm
+		}
+		results = append(results, res)
+	}
+	return results
+}
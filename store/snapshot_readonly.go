@@ -0,0 +1,144 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/raft"
+)
+
+const (
+	snapshotsDirName = "snapshots"
+	snapshotMetaName = "meta.json"
+	snapshotDataName = "state.bin"
+)
+
+// errReadOnlySnapshotStore is returned when a snapshot is created in a
+// readOnlySnapshotStore.
+var errReadOnlySnapshotStore = errors.New("snapshot store is read-only")
+
+// readOnlySnapshotStore reads the snapshots written by a raft.FileSnapshotStore,
+// without creating or modifying anything on disk. It is for use on the data
+// directory of a stopped node, which may be read-only or damaged.
+type readOnlySnapshotStore struct {
+	dir string
+}
+
+// newReadOnlySnapshotStore returns a readOnlySnapshotStore for the snapshots
+// in the given data directory.
+func newReadOnlySnapshotStore(dataDir string) *readOnlySnapshotStore {
+	return &readOnlySnapshotStore{
+		dir: filepath.Join(dataDir, snapshotsDirName),
+	}
+}
+
+// readOnlySnapshotMeta is the format of the snapshot metadata file.
+type readOnlySnapshotMeta struct {
+	raft.SnapshotMeta
+	CRC []byte
+}
+
+// Create always returns an error, as the store is read-only.
+func (r *readOnlySnapshotStore) Create(version raft.SnapshotVersion, index, term uint64,
+	configuration raft.Configuration, configurationIndex uint64, trans raft.Transport) (raft.SnapshotSink, error) {
+	return nil, errReadOnlySnapshotStore
+}
+
+// List returns the metadata of all readable snapshots, newest first. If the
+// snapshot directory does not exist, no snapshots are returned.
+func (r *readOnlySnapshotStore) List() ([]*raft.SnapshotMeta, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var metas []*readOnlySnapshotMeta
+	for _, e := range entries {
+		// Snapshots which were never completed have a temporary suffix.
+		if !e.IsDir() || strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+		meta, err := r.readMeta(e.Name())
+		if err != nil {
+			continue
+		}
+		metas = append(metas, meta)
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].Term != metas[j].Term {
+			return metas[i].Term > metas[j].Term
+		}
+		if metas[i].Index != metas[j].Index {
+			return metas[i].Index > metas[j].Index
+		}
+		return metas[i].ID > metas[j].ID
+	})
+
+	snaps := make([]*raft.SnapshotMeta, len(metas))
+	for i := range metas {
+		snaps[i] = &metas[i].SnapshotMeta
+	}
+	return snaps, nil
+}
+
+// Open returns the snapshot with the given ID, after checking its data has
+// not been corrupted.
+func (r *readOnlySnapshotStore) Open(id string) (*raft.SnapshotMeta, io.ReadCloser, error) {
+	meta, err := r.readMeta(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fh, err := os.Open(filepath.Join(r.dir, id, snapshotDataName))
+	if err != nil {
+		return nil, nil, err
+	}
+	h := crc64.New(crc64.MakeTable(crc64.ECMA))
+	if _, err := io.Copy(h, fh); err != nil {
+		fh.Close()
+		return nil, nil, err
+	}
+	if !bytes.Equal(h.Sum(nil), meta.CRC) {
+		fh.Close()
+		return nil, nil, fmt.Errorf("snapshot %s is corrupt, CRC mismatch", id)
+	}
+	if _, err := fh.Seek(0, io.SeekStart); err != nil {
+		fh.Close()
+		return nil, nil, err
+	}
+	return &meta.SnapshotMeta, &bufferedFile{bufio.NewReader(fh), fh}, nil
+}
+
+func (r *readOnlySnapshotStore) readMeta(id string) (*readOnlySnapshotMeta, error) {
+	b, err := os.ReadFile(filepath.Join(r.dir, id, snapshotMetaName))
+	if err != nil {
+		return nil, err
+	}
+	meta := &readOnlySnapshotMeta{}
+	if err := json.Unmarshal(b, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// bufferedFile is a buffered reader over a file, which closes the file.
+type bufferedFile struct {
+	*bufio.Reader
+	fh *os.File
+}
+
+func (b *bufferedFile) Close() error {
+	return b.fh.Close()
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/raft"
+)
+
+func Test_ReadOnlySnapshotStore(t *testing.T) {
+	dir := t.TempDir()
+	fss, err := raft.NewFileSnapshotStore(dir, 2, io.Discard)
+	if err != nil {
+		t.Fatalf("failed to create file snapshot store: %s", err.Error())
+	}
+	for i, data := range []string{"first", "second"} {
+		sink, err := fss.Create(raft.SnapshotVersionMax, uint64(10*(i+1)), 1, raft.Configuration{}, 1, nil)
+		if err != nil {
+			t.Fatalf("failed to create snapshot: %s", err.Error())
+		}
+		if _, err := sink.Write([]byte(data)); err != nil {
+			t.Fatalf("failed to write snapshot: %s", err.Error())
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("failed to close snapshot: %s", err.Error())
+		}
+	}
+
+	ros := newReadOnlySnapshotStore(dir)
+	if _, err := ros.Create(raft.SnapshotVersionMax, 30, 1, raft.Configuration{}, 1, nil); err == nil {
+		t.Fatalf("created snapshot in read-only store")
+	}
+	snaps, err := ros.List()
+	if err != nil {
+		t.Fatalf("failed to list snapshots: %s", err.Error())
+	}
+	if len(snaps) != 2 {
+		t.Fatalf("wrong number of snapshots, exp 2, got %d", len(snaps))
+	}
+	if snaps[0].Index != 20 || snaps[1].Index != 10 {
+		t.Fatalf("snapshots not listed newest first, got indexes %d, %d", snaps[0].Index, snaps[1].Index)
+	}
+
+	meta, rc, err := ros.Open(snaps[0].ID)
+	if err != nil {
+		t.Fatalf("failed to open snapshot: %s", err.Error())
+	}
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %s", err.Error())
+	}
+	if exp, got := "second", string(b); exp != got {
+		t.Fatalf("wrong snapshot data, exp %s, got %s", exp, got)
+	}
+	if meta.Index != 20 {
+		t.Fatalf("wrong snapshot index, exp 20, got %d", meta.Index)
+	}
+
+	// A corrupt snapshot must not be opened.
+	statePath := filepath.Join(dir, snapshotsDirName, snaps[1].ID, snapshotDataName)
+	if err := os.WriteFile(statePath, []byte("corrupt"), 0644); err != nil {
+		t.Fatalf("failed to corrupt snapshot: %s", err.Error())
+	}
+	if _, _, err := ros.Open(snaps[1].ID); err == nil {
+		t.Fatalf("opened corrupt snapshot")
+	}
+}
+
+func Test_ReadOnlySnapshotStoreNoDir(t *testing.T) {
+	dir := t.TempDir()
+	snaps, err := newReadOnlySnapshotStore(dir).List()
+	if err != nil {
+		t.Fatalf("failed to list snapshots: %s", err.Error())
+	}
+	if len(snaps) != 0 {
+		t.Fatalf("expected no snapshots, got %d", len(snaps))
+	}
+	if pathExists(filepath.Join(dir, snapshotsDirName)) {
+		t.Fatalf("read-only snapshot store created snapshot directory")
+	}
+}
@@ -61,6 +61,9 @@ var (
 	// ErrInvalidBackupFormat is returned when the requested backup format
 	// is not valid.
 	ErrInvalidBackupFormat = errors.New("invalid backup format")
+
+	// ErrResyncLeader is returned when a resync of the Leader is requested.
+	ErrResyncLeader = errors.New("cannot resync leader")
 )
 
 const (
@@ -79,6 +82,7 @@ const (
 	raftLogCacheSize           = 512
 	trailingScale              = 1.25
 	observerChanLen            = 50
+	offlineLockTimeout         = 2 * time.Second
 
 	defaultChunkSize = 512 * 1024 * 1024 // 512 MB
 )
@@ -108,6 +112,10 @@ const (
 	failedHeartbeatObserved  = "failed_heartbeat_observed"
 	nodesReapedOK            = "nodes_reaped_ok"
 	nodesReapedFailed        = "nodes_reaped_failed"
+	numCoalescedExecutes     = "num_coalesced_executes"
+	numCoalescedEntries      = "num_coalesced_entries"
+	numResyncs               = "num_resyncs"
+	numResyncsFailed         = "num_resyncs_failed"
 )
 
 // stats captures stats for the Store.
@@ -144,6 +152,10 @@ func ResetStats() {
 	stats.Add(failedHeartbeatObserved, 0)
 	stats.Add(nodesReapedOK, 0)
 	stats.Add(nodesReapedFailed, 0)
+	stats.Add(numCoalescedExecutes, 0)
+	stats.Add(numCoalescedEntries, 0)
+	stats.Add(numResyncs, 0)
+	stats.Add(numResyncsFailed, 0)
 }
 
 // ClusterState defines the possible Raft states the current node can be in
@@ -192,14 +204,20 @@ type Store struct {
 
 	// Latest log entry index actually reflected by the FSM. Due to Raft code
 	// this value is not updated after a Snapshot-restore.
-	fsmIndex   uint64
-	fsmIndexMu sync.RWMutex
+	fsmIndex    uint64
+	fsmRestored bool // Snapshot restored since fsmIndex was last set.
+	fsmIndexMu  sync.RWMutex
 
 	reqMarshaller *command.RequestMarshaler // Request marshaler for writing to log.
+	coalescer     *coalescer                // Merges Execute requests, if enabled.
 	raftLog       raft.LogStore             // Persistent log store.
+	raftSnaps     raft.SnapshotStore        // Persistent snapshot store.
 	raftStable    raft.StableStore          // Persistent k-v store.
 	boltStore     *rlog.Log                 // Physical store.
 
+	lastResyncMu sync.RWMutex
+	lastResync   *ResyncStatus // Outcome of most recent resync, if any.
+
 	// Raft changes observer
 	leaderObserversMu sync.RWMutex
 	leaderObservers   []chan<- struct{}
@@ -234,6 +252,12 @@ type Store struct {
 	RaftLogLevel       string
 	NoFreeListSync     bool
 
+	// Execute request coalescing configuration. If CoalesceBatchSz is greater
+	// than 1, up to that many concurrent Execute requests are merged into a
+	// single Raft log entry.
+	CoalesceBatchSz int
+	CoalesceTimeout time.Duration
+
 	// Node-reaping configuration
 	ReapTimeout         time.Duration
 	ReapReadOnlyTimeout time.Duration
@@ -385,6 +409,7 @@ func (s *Store) Open() (retErr error) {
 		return fmt.Errorf("list snapshots: %s", err)
 	}
 	s.logger.Printf("%d preexisting snapshots present", len(snaps))
+	s.raftSnaps = snapshots
 
 	// Create the log store and stable store.
 	s.boltStore, err = rlog.New(filepath.Join(s.raftDir, raftDBPath), s.NoFreeListSync)
@@ -458,6 +483,12 @@ func (s *Store) Open() (retErr error) {
 	// Periodically update the applied index for faster startup.
 	s.appliedIdxUpdateDone = s.updateAppliedIndex()
 
+	if s.CoalesceBatchSz > 1 {
+		s.coalescer = newCoalescer(s.CoalesceBatchSz, s.CoalesceTimeout, s.execute)
+		s.logger.Printf("execute coalescing enabled with batch size %d, timeout %s",
+			s.CoalesceBatchSz, s.CoalesceTimeout)
+	}
+
 	return nil
 }
 
@@ -540,6 +571,10 @@ func (s *Store) Close(wait bool) (retErr error) {
 	close(s.observerClose)
 	<-s.observerDone
 
+	if s.coalescer != nil {
+		s.coalescer.Close()
+	}
+
 	f := s.raft.Shutdown()
 	if wait {
 		if f.Error() != nil {
@@ -919,6 +954,8 @@ func (s *Store) Stats() (map[string]interface{}, error) {
 		"reap_timeout":           s.ReapTimeout.String(),
 		"reap_read_only_timeout": s.ReapReadOnlyTimeout.String(),
 		"no_freelist_sync":       s.NoFreeListSync,
+		"coalesce_batch_size":    s.CoalesceBatchSz,
+		"coalesce_timeout":       s.CoalesceTimeout.String(),
 		"trailing_logs":          s.numTrailingLogs,
 		"request_marshaler":      s.reqMarshaller.Stats(),
 		"nodes":                  nodes,
@@ -927,6 +964,9 @@ func (s *Store) Stats() (map[string]interface{}, error) {
 		"sqlite3":                dbStatus,
 		"db_conf":                s.dbConf,
 	}
+	if rs := s.LastResync(); rs != nil {
+		status["last_resync"] = rs
+	}
 	return status, nil
 }
 
@@ -943,6 +983,9 @@ func (s *Store) Execute(ex *command.ExecuteRequest) ([]*command.ExecuteResult, e
 		return nil, ErrNotReady
 	}
 
+	if s.coalescer != nil && canCoalesce(ex) {
+		return s.coalescer.Execute(ex)
+	}
 	return s.execute(ex)
 }
 
@@ -1157,6 +1200,20 @@ func (s *Store) Provide(path string) error {
 	return nil
 }
 
+// AppliedIndex returns the index of the last Raft log entry applied by the FSM.
+// Raft's own applied index is not used, as it advances before the FSM applies
+// an entry, and so may be ahead of the database. If a snapshot has been restored
+// since, its index is not known, and 0 is returned. It implements the uploader
+// IndexProvider interface.
+func (s *Store) AppliedIndex() uint64 {
+	s.fsmIndexMu.RLock()
+	defer s.fsmIndexMu.RUnlock()
+	if s.fsmRestored {
+		return 0
+	}
+	return s.fsmIndex
+}
+
 // LoadFromReader reads data from r chunk-by-chunk, and loads it into the
 // database.
 func (s *Store) LoadFromReader(r io.Reader, chunkSize int64) error {
@@ -1419,6 +1476,163 @@ func (s *Store) Remove(rn *command.RemoveNodeRequest) error {
 	return nil
 }
 
+// ResyncStatus is the outcome of a resync of a node.
+type ResyncStatus struct {
+	NodeID string    `json:"node_id"`
+	Time   time.Time `json:"time"`
+	Index  uint64    `json:"index,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Resync rebuilds the state of the node with the given ID from a fresh snapshot
+// of this node. It is intended for use when the database of a follower is known,
+// or suspected, to have diverged from the rest of the cluster. The follower
+// discards its database, installs the snapshot, and then applies any later log
+// entries as normal. Resync must be called on the Leader.
+func (s *Store) Resync(id string) (retErr error) {
+	if !s.open {
+		return ErrNotOpen
+	}
+	if s.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+	if id == s.raftID {
+		return ErrResyncLeader
+	}
+	term, err := s.currentTerm()
+	if err != nil {
+		return err
+	}
+
+	startT := time.Now()
+	var snapIdx uint64
+	defer func() {
+		rs := &ResyncStatus{
+			NodeID: id,
+			Time:   startT,
+			Index:  snapIdx,
+		}
+		if retErr != nil {
+			rs.Error = retErr.Error()
+		}
+		s.lastResyncMu.Lock()
+		s.lastResync = rs
+		s.lastResyncMu.Unlock()
+
+		if retErr != nil {
+			stats.Add(numResyncsFailed, 1)
+			s.logger.Printf("failed to resync node %s: %s", id, retErr.Error())
+			return
+		}
+		stats.Add(numResyncs, 1)
+	}()
+
+	cf := s.raft.GetConfiguration()
+	if err := cf.Error(); err != nil {
+		return err
+	}
+	var srv *raft.Server
+	for i := range cf.Configuration().Servers {
+		if cf.Configuration().Servers[i].ID == raft.ServerID(id) {
+			srv = &cf.Configuration().Servers[i]
+			break
+		}
+	}
+	if srv == nil {
+		return fmt.Errorf("node %s is not a member of the cluster", id)
+	}
+	s.logger.Printf("received request to resync node %s at %s", id, srv.Address)
+
+	// Snapshot now, so the follower has as few log entries as possible to apply
+	// on top of the snapshot. If nothing has changed since the last snapshot,
+	// the last snapshot is used instead.
+	if err := s.raft.Snapshot().Error(); err != nil && err != raft.ErrNothingNewToSnapshot {
+		return fmt.Errorf("snapshot: %s", err.Error())
+	}
+	snaps, err := s.raftSnaps.List()
+	if err != nil {
+		return fmt.Errorf("list snapshots: %s", err.Error())
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("no snapshot available")
+	}
+	meta, rc, err := s.raftSnaps.Open(snaps[0].ID)
+	if err != nil {
+		return fmt.Errorf("open snapshot: %s", err.Error())
+	}
+	defer rc.Close()
+	snapIdx = meta.Index
+
+	// Send the snapshot to the follower exactly as Raft does for a follower
+	// which has fallen too far behind. The follower processes Raft RPCs one at
+	// a time, so this cannot interleave with normal log replication.
+	req := &raft.InstallSnapshotRequest{
+		RPCHeader: raft.RPCHeader{
+			ProtocolVersion: raft.ProtocolVersionMax,
+			ID:              []byte(s.raftID),
+			Addr:            s.raftTn.EncodePeer(raft.ServerID(s.raftID), s.raftTn.LocalAddr()),
+		},
+		SnapshotVersion:    meta.Version,
+		Term:               term,
+		Leader:             s.raftTn.EncodePeer(raft.ServerID(s.raftID), s.raftTn.LocalAddr()),
+		LastLogIndex:       meta.Index,
+		LastLogTerm:        meta.Term,
+		Peers:              meta.Peers,
+		Configuration:      raft.EncodeConfiguration(meta.Configuration),
+		ConfigurationIndex: meta.ConfigurationIndex,
+		Size:               meta.Size,
+	}
+
+	// Leadership may have been lost while snapshotting. A request sent in an
+	// earlier term must not be sent, as it would claim a leadership this node
+	// no longer holds.
+	if s.raft.State() != raft.Leader {
+		return ErrNotLeader
+	}
+	if t, err := s.currentTerm(); err != nil {
+		return err
+	} else if t != term {
+		return ErrNotLeader
+	}
+
+	resp := &raft.InstallSnapshotResponse{}
+	if err := s.raftTn.InstallSnapshot(srv.ID, srv.Address, req, resp, rc); err != nil {
+		return fmt.Errorf("install snapshot: %s", err.Error())
+	}
+	if !resp.Success {
+		return fmt.Errorf("node %s rejected snapshot at term %d", id, resp.Term)
+	}
+
+	// The follower only applies log entries after the snapshot once the commit
+	// index advances, so ensure it does.
+	if err := s.Noop(fmt.Sprintf("resync-%s", id)); err != nil {
+		return err
+	}
+	s.logger.Printf("node %s resynced from snapshot at index %d in %s", id, meta.Index, time.Since(startT))
+	return nil
+}
+
+// LastResync returns the outcome of the most recent resync requested of this
+// node, or nil if there has been none.
+func (s *Store) LastResync() *ResyncStatus {
+	s.lastResyncMu.RLock()
+	defer s.lastResyncMu.RUnlock()
+	if s.lastResync == nil {
+		return nil
+	}
+	rs := *s.lastResync
+	return &rs
+}
+
+// currentTerm returns the current Raft term of this node.
+func (s *Store) currentTerm() (uint64, error) {
+	term, err := strconv.ParseUint(s.raft.Stats()["term"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("current term: %s", err.Error())
+	}
+	return term, nil
+}
+
 // Noop writes a noop command to the Raft log. A noop command simply
 // consumes a slot in the Raft log, but has no other effect on the
 // system.
@@ -1575,6 +1789,7 @@ func (s *Store) Apply(l *raft.Log) (e interface{}) {
 		s.fsmIndexMu.Lock()
 		defer s.fsmIndexMu.Unlock()
 		s.fsmIndex = l.Index
+		s.fsmRestored = false
 
 		if l.Index <= s.lastCommandIdxOnOpen {
 			// In here means at least one command entry was in the log when the Store
@@ -1674,6 +1889,10 @@ func (s *Store) Restore(rc io.ReadCloser) error {
 	}
 	s.db = db
 
+	s.fsmIndexMu.Lock()
+	s.fsmRestored = true
+	s.fsmIndexMu.Unlock()
+
 	stats.Add(numRestores, 1)
 	s.logger.Printf("node restored in %s", time.Since(startT))
 	rc.Close()
@@ -1842,6 +2061,99 @@ func RecoverNode(dataDir string, logger *log.Logger, logs raft.LogStore, stable
 		return err
 	}
 
+	db, lastIndex, lastTerm, err := replayRaftState(dataDir, logger, logs, snaps)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Create a new snapshot, placing the configuration in as if it was
+	// committed at index 1.
+	snapshot := NewFSMSnapshot(db, logger)
+	sink, err := snaps.Create(1, lastIndex, lastTerm, conf, 1, tn)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	if err = snapshot.Persist(sink); err != nil {
+		return fmt.Errorf("failed to persist snapshot: %v", err)
+	}
+	if err = sink.Close(); err != nil {
+		return fmt.Errorf("failed to finalize snapshot: %v", err)
+	}
+	logger.Printf("recovery snapshot created successfully")
+
+	// Compact the log so that we don't get bad interference from any
+	// configuration change log entries that might be there.
+	firstLogIndex, err := logs.FirstIndex()
+	if err != nil {
+		return fmt.Errorf("failed to get first log index: %v", err)
+	}
+	lastLogIndex, err := logs.LastIndex()
+	if err != nil {
+		return fmt.Errorf("failed to get last log index: %v", err)
+	}
+	if err := logs.DeleteRange(firstLogIndex, lastLogIndex); err != nil {
+		return fmt.Errorf("log compaction failed: %v", err)
+	}
+
+	// Erase record of previous updating of Applied Index too.
+	if err := stable.SetAppliedIndex(0); err != nil {
+		return fmt.Errorf("failed to zero applied index: %v", err)
+	}
+
+	return nil
+}
+
+// BackupOffline writes a SQLite copy of the database represented by the Raft
+// data in dataDir to the file at path. The node owning dataDir must not be
+// running. The newest snapshot is restored, and every entry in the Raft log
+// after that snapshot is then applied, so the backup reflects all changes
+// written to this node's log, whether or not they had been committed by the
+// cluster. The index of the last applied log entry is returned.
+func BackupOffline(dataDir string, logger *log.Logger, path string) (uint64, error) {
+	if logger == nil {
+		logger = log.New(os.Stderr, "[store] ", log.LstdFlags)
+	}
+
+	raftDB := filepath.Join(dataDir, raftDBPath)
+	if !pathExists(raftDB) {
+		return 0, fmt.Errorf("no Raft data found at %s", raftDB)
+	}
+	logs, err := rlog.NewReadOnly(raftDB, offlineLockTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open Raft log, is the node still running? %s", err.Error())
+	}
+	defer logs.Close()
+
+	// Snapshots are read directly, as a raft.FileSnapshotStore writes to the
+	// snapshot directory when it is created. Any chunked loads are dechunked
+	// to a scratch directory, so that nothing in the data directory is modified.
+	snaps := newReadOnlySnapshotStore(dataDir)
+	scratchDir, err := os.MkdirTemp("", "rqlite-backup-offline-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(scratchDir)
+
+	db, lastIndex, _, err := replayRaftState(scratchDir, logger, logs, snaps)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if err := db.Backup(path); err != nil {
+		return 0, err
+	}
+	return lastIndex, nil
+}
+
+// replayRaftState creates an in-memory database by restoring the newest
+// readable snapshot in snaps, and then applying all Raft log entries after
+// that snapshot. It returns the database, along with the index and term of
+// the last entry reflected in it. Any chunked load requests found in the log
+// are dechunked to temporary files in dataDir.
+func replayRaftState(dataDir string, logger *log.Logger, logs raft.LogStore,
+	snaps raft.SnapshotStore) (*sql.DB, uint64, uint64, error) {
 	// Attempt to restore any snapshots we find, newest to oldest.
 	var (
 		snapshotIndex  uint64
@@ -1849,9 +2161,9 @@ func RecoverNode(dataDir string, logger *log.Logger, logs raft.LogStore, stable
 		snapshots, err = snaps.List()
 	)
 	if err != nil {
-		return fmt.Errorf("failed to list snapshots: %v", err)
+		return nil, 0, 0, fmt.Errorf("failed to list snapshots: %v", err)
 	}
-	logger.Printf("recovery detected %d snapshots", len(snapshots))
+	logger.Printf("detected %d snapshots", len(snapshots))
 
 	var b []byte
 	for _, snapshot := range snapshots {
@@ -1876,11 +2188,10 @@ func RecoverNode(dataDir string, logger *log.Logger, logs raft.LogStore, stable
 		break
 	}
 	if len(snapshots) > 0 && (snapshotIndex == 0 || snapshotTerm == 0) {
-		return fmt.Errorf("failed to restore any of the available snapshots")
+		return nil, 0, 0, fmt.Errorf("failed to restore any of the available snapshots")
 	}
 
-	// Now, create an in-memory database for temporary use, so we can generate new
-	// snapshots later.
+	// Now, create an in-memory database for temporary use.
 	var db *sql.DB
 	if len(b) == 0 {
 		db, err = sql.OpenInMemory(false)
@@ -1888,14 +2199,14 @@ func RecoverNode(dataDir string, logger *log.Logger, logs raft.LogStore, stable
 		db, err = sql.DeserializeIntoMemory(b, false)
 	}
 	if err != nil {
-		return fmt.Errorf("create in-memory database failed: %s", err)
+		return nil, 0, 0, fmt.Errorf("create in-memory database failed: %s", err)
 	}
-	defer db.Close()
 
 	// Need a dechunker manager to handle any chunked load requests.
 	decMgmr, err := chunking.NewDechunkerManager(dataDir)
 	if err != nil {
-		return fmt.Errorf("failed to create dechunker manager: %s", err.Error())
+		db.Close()
+		return nil, 0, 0, fmt.Errorf("failed to create dechunker manager: %s", err.Error())
 	}
 
 	// The snapshot information is the best known end point for the data
@@ -1906,14 +2217,16 @@ func RecoverNode(dataDir string, logger *log.Logger, logs raft.LogStore, stable
 	// Apply any Raft log entries past the snapshot.
 	lastLogIndex, err := logs.LastIndex()
 	if err != nil {
-		return fmt.Errorf("failed to find last log: %v", err)
+		db.Close()
+		return nil, 0, 0, fmt.Errorf("failed to find last log: %v", err)
 	}
-	logger.Printf("recovery snapshot index is %d, last index is %d", snapshotIndex, lastLogIndex)
+	logger.Printf("snapshot index is %d, last index is %d", snapshotIndex, lastLogIndex)
 
 	for index := snapshotIndex + 1; index <= lastLogIndex; index++ {
 		var entry raft.Log
 		if err = logs.GetLog(index, &entry); err != nil {
-			return fmt.Errorf("failed to get log at index %d: %v", index, err)
+			db.Close()
+			return nil, 0, 0, fmt.Errorf("failed to get log at index %d: %v", index, err)
 		}
 		if entry.Type == raft.LogCommand {
 			applyCommand(entry.Data, &db, decMgmr)
@@ -1921,38 +2234,7 @@ func RecoverNode(dataDir string, logger *log.Logger, logs raft.LogStore, stable
 		lastIndex = entry.Index
 		lastTerm = entry.Term
 	}
-
-	// Create a new snapshot, placing the configuration in as if it was
-	// committed at index 1.
-	snapshot := NewFSMSnapshot(db, logger)
-	sink, err := snaps.Create(1, lastIndex, lastTerm, conf, 1, tn)
-	if err != nil {
-		return fmt.Errorf("failed to create snapshot: %v", err)
-	}
-	if err = snapshot.Persist(sink); err != nil {
-		return fmt.Errorf("failed to persist snapshot: %v", err)
-	}
-	if err = sink.Close(); err != nil {
-		return fmt.Errorf("failed to finalize snapshot: %v", err)
-	}
-	logger.Printf("recovery snapshot created successfully")
-
-	// Compact the log so that we don't get bad interference from any
-	// configuration change log entries that might be there.
-	firstLogIndex, err := logs.FirstIndex()
-	if err != nil {
-		return fmt.Errorf("failed to get first log index: %v", err)
-	}
-	if err := logs.DeleteRange(firstLogIndex, lastLogIndex); err != nil {
-		return fmt.Errorf("log compaction failed: %v", err)
-	}
-
-	// Erase record of previous updating of Applied Index too.
-	if err := stable.SetAppliedIndex(0); err != nil {
-		return fmt.Errorf("failed to zero applied index: %v", err)
-	}
-
-	return nil
+	return db, lastIndex, lastTerm, nil
 }
 
 func dbBytesFromSnapshot(rc io.ReadCloser) ([]byte, error) {
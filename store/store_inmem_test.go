@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -377,6 +378,75 @@ func Test_SingleNodeExecuteQueryTx(t *testing.T) {
 
 // Test_SingleNodeInMemRequest tests simple requests that contain both
 // queries and execute statements.
+// Test_SingleNodeExecuteCoalesce tests that concurrent Execute requests are
+// merged, and that each request receives its own results.
+func Test_SingleNodeExecuteCoalesce(t *testing.T) {
+	s, ln := mustNewStore(t, true)
+	defer ln.Close()
+	s.CoalesceBatchSz = 16
+	s.CoalesceTimeout = 10 * time.Millisecond
+
+	if err := s.Open(); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	if err := s.Bootstrap(NewServer(s.ID(), s.Addr(), true)); err != nil {
+		t.Fatalf("failed to bootstrap single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	if _, err := s.WaitForLeader(10 * time.Second); err != nil {
+		t.Fatalf("Error waiting for leader: %s", err)
+	}
+
+	er := executeRequestFromString(`CREATE TABLE foo (id INTEGER NOT NULL PRIMARY KEY, name TEXT)`, false, false)
+	if _, err := s.Execute(er); err != nil {
+		t.Fatalf("failed to execute on single node: %s", err.Error())
+	}
+	idxBefore := s.raft.AppliedIndex()
+
+	const numWrites = 50
+	var wg sync.WaitGroup
+	for i := 1; i <= numWrites; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			stmts := []string{fmt.Sprintf(`INSERT INTO foo(id, name) VALUES(%d, "fiona")`, id)}
+			if id%10 == 0 {
+				// A failing statement must only affect its own request.
+				stmts = append(stmts, `INSERT INTO bar(id) VALUES(1)`)
+			}
+			r, err := s.Execute(executeRequestFromStrings(stmts, false, false))
+			if err != nil {
+				t.Errorf("failed to execute on single node: %s", err.Error())
+				return
+			}
+			if len(r) != len(stmts) {
+				t.Errorf("wrong number of results for %d, exp %d, got %d", id, len(stmts), len(r))
+				return
+			}
+			if r[0].LastInsertId != int64(id) || r[0].RowsAffected != 1 || r[0].Error != "" {
+				t.Errorf("wrong result for %d: %s", id, asJSON(r[0]))
+			}
+			if len(r) == 2 && r[1].Error != "no such table: bar" {
+				t.Errorf("wrong error for %d: %s", id, asJSON(r[1]))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	qr := queryRequestFromString("SELECT COUNT(*) FROM foo", false, false)
+	qr.Level = command.QueryRequest_QUERY_REQUEST_LEVEL_NONE
+	r, err := s.Query(qr)
+	if err != nil {
+		t.Fatalf("failed to query single node: %s", err.Error())
+	}
+	if exp, got := `[[50]]`, asJSON(r[0].Values); exp != got {
+		t.Fatalf("unexpected results for query\nexp: %s\ngot: %s", exp, got)
+	}
+	if n := s.raft.AppliedIndex() - idxBefore; n >= numWrites {
+		t.Fatalf("writes were not coalesced, %d log entries applied", n)
+	}
+}
+
 func Test_SingleNodeInMemRequest(t *testing.T) {
 	s, ln := mustNewStore(t, true)
 	defer ln.Close()
@@ -1152,6 +1222,72 @@ func Test_SingleNodeInMemProvideNoData(t *testing.T) {
 	}
 }
 
+// Test_SingleNodeAppliedIndexNotAheadOfData tests that the applied index the
+// Store reports is not ahead of the data it provides, even when Raft considers
+// log entries applied which the FSM has not yet applied.
+func Test_SingleNodeAppliedIndexNotAheadOfData(t *testing.T) {
+	s, ln := mustNewStore(t, true)
+	defer ln.Close()
+
+	if err := s.Open(); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	if err := s.Bootstrap(NewServer(s.ID(), s.Addr(), true)); err != nil {
+		t.Fatalf("failed to bootstrap single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	if _, err := s.WaitForLeader(10 * time.Second); err != nil {
+		t.Fatalf("Error waiting for leader: %s", err)
+	}
+
+	er := executeRequestFromString(`CREATE TABLE foo (id INTEGER NOT NULL PRIMARY KEY, name TEXT)`, false, false)
+	if _, err := s.Execute(er); err != nil {
+		t.Fatalf("failed to execute on single node: %s", err.Error())
+	}
+	raftIdx := s.raft.AppliedIndex()
+
+	// Block the FSM once it has applied the first insert, so Raft moves on to
+	// the second insert before the FSM has applied it.
+	s.fsmIndexMu.Lock()
+	var wg sync.WaitGroup
+	for i := 1; i <= 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			er := executeRequestFromString(fmt.Sprintf(`INSERT INTO foo(id, name) VALUES(%d, "fiona")`, i), false, false)
+			if _, err := s.Execute(er); err != nil {
+				t.Errorf("failed to execute on single node: %s", err.Error())
+			}
+		}(i)
+	}
+	testPoll(t, func() bool {
+		return s.raft.AppliedIndex() >= raftIdx+2
+	}, 100*time.Millisecond, 5*time.Second)
+
+	// Read the index and then the data, in the same order as the uploader.
+	type indexedCount struct {
+		index uint64
+		count int64
+	}
+	ch := make(chan indexedCount)
+	go func() {
+		idx := s.AppliedIndex()
+		rows, err := s.db.QueryStringStmt("SELECT COUNT(*) FROM foo")
+		if err != nil {
+			t.Errorf("failed to query database: %s", err.Error())
+		}
+		ch <- indexedCount{idx, rows[0].Values[0].Parameters[0].GetI()}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	s.fsmIndexMu.Unlock()
+
+	ic := <-ch
+	if ic.index > raftIdx+uint64(ic.count) {
+		t.Fatalf("applied index %d is ahead of data with %d rows written after index %d", ic.index, ic.count, raftIdx)
+	}
+	wg.Wait()
+}
+
 // Test_SingleNodeRecoverNoChange tests a node recovery that doesn't
 // actually change anything.
 func Test_SingleNodeRecoverNoChange(t *testing.T) {
@@ -2015,6 +2151,117 @@ func Test_SingleNodeExecuteQueryFreshness(t *testing.T) {
 	}
 }
 
+func Test_MultiNodeResync(t *testing.T) {
+	ResetStats()
+	s0, ln0 := mustNewStore(t, true)
+	defer ln0.Close()
+	if err := s0.Open(); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s0.Close(true)
+	if err := s0.Bootstrap(NewServer(s0.ID(), s0.Addr(), true)); err != nil {
+		t.Fatalf("failed to bootstrap single-node store: %s", err.Error())
+	}
+	if _, err := s0.WaitForLeader(10 * time.Second); err != nil {
+		t.Fatalf("Error waiting for leader: %s", err)
+	}
+
+	s1, ln1 := mustNewStore(t, true)
+	defer ln1.Close()
+	if err := s1.Open(); err != nil {
+		t.Fatalf("failed to open node for multi-node test: %s", err.Error())
+	}
+	defer s1.Close(true)
+	if err := s0.Join(joinRequest(s1.ID(), s1.Addr(), true)); err != nil {
+		t.Fatalf("failed to join to node at %s: %s", s0.Addr(), err.Error())
+	}
+	if _, err := s1.WaitForLeader(10 * time.Second); err != nil {
+		t.Fatalf("Error waiting for leader: %s", err)
+	}
+
+	er := executeRequestFromStrings([]string{
+		`CREATE TABLE foo (id INTEGER NOT NULL PRIMARY KEY, name TEXT)`,
+		`INSERT INTO foo(id, name) VALUES(1, "fiona")`,
+	}, false, false)
+	if _, err := s0.Execute(er); err != nil {
+		t.Fatalf("failed to execute on single node: %s", err.Error())
+	}
+	s0FsmIdx, err := s0.WaitForAppliedFSM(5 * time.Second)
+	if err != nil {
+		t.Fatalf("failed to wait for fsmIndex: %s", err.Error())
+	}
+	if _, err := s1.WaitForFSMIndex(s0FsmIdx, 5*time.Second); err != nil {
+		t.Fatalf("error waiting for follower to apply index: %s:", err.Error())
+	}
+
+	// Cause the follower's database to diverge from the Leader's.
+	if _, err := s1.db.ExecuteStringStmt(`INSERT INTO foo(id, name) VALUES(2, "declan")`); err != nil {
+		t.Fatalf("failed to write directly to follower database: %s", err.Error())
+	}
+	qr := queryRequestFromString("SELECT * FROM foo", false, false)
+	qr.Level = command.QueryRequest_QUERY_REQUEST_LEVEL_NONE
+	r, err := s1.Query(qr)
+	if err != nil {
+		t.Fatalf("failed to query follower node: %s", err.Error())
+	}
+	if exp, got := `[[1,"fiona"],[2,"declan"]]`, asJSON(r[0].Values); exp != got {
+		t.Fatalf("unexpected results for query\nexp: %s\ngot: %s", exp, got)
+	}
+
+	if err := s1.Resync(s0.ID()); err != ErrNotLeader {
+		t.Fatalf("expected ErrNotLeader resyncing on follower, got %v", err)
+	}
+	if err := s0.Resync(s0.ID()); err != ErrResyncLeader {
+		t.Fatalf("expected ErrResyncLeader resyncing Leader, got %v", err)
+	}
+	if err := s0.Resync("no-such-node"); err == nil {
+		t.Fatalf("expected error resyncing unknown node")
+	}
+	if rs := s0.LastResync(); rs == nil || rs.NodeID != "no-such-node" || rs.Error == "" {
+		t.Fatalf("failed resync not recorded, got %+v", rs)
+	}
+
+	// The follower must discard the diverged row, and continue to apply
+	// log entries once the snapshot is installed.
+	if err := s0.Resync(s1.ID()); err != nil {
+		t.Fatalf("failed to resync follower: %s", err.Error())
+	}
+	if _, err := s0.Execute(executeRequestFromString(`INSERT INTO foo(id, name) VALUES(3, "fiona")`,
+		false, false)); err != nil {
+		t.Fatalf("failed to execute on single node: %s", err.Error())
+	}
+	s0FsmIdx, err = s0.WaitForAppliedFSM(5 * time.Second)
+	if err != nil {
+		t.Fatalf("failed to wait for fsmIndex: %s", err.Error())
+	}
+	if _, err := s1.WaitForFSMIndex(s0FsmIdx, 5*time.Second); err != nil {
+		t.Fatalf("error waiting for follower to apply index: %s:", err.Error())
+	}
+	r, err = s1.Query(qr)
+	if err != nil {
+		t.Fatalf("failed to query follower node: %s", err.Error())
+	}
+	if exp, got := `[[1,"fiona"],[3,"fiona"]]`, asJSON(r[0].Values); exp != got {
+		t.Fatalf("unexpected results for query after resync\nexp: %s\ngot: %s", exp, got)
+	}
+	if exp, got := int64(1), stats.Get(numResyncs).(*expvar.Int).Value(); exp != got {
+		t.Fatalf("wrong number of resyncs, exp %d, got %d", exp, got)
+	}
+
+	// The resync must be visible in the status of the Leader.
+	st, err := s0.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %s", err.Error())
+	}
+	rs, ok := st["last_resync"].(*ResyncStatus)
+	if !ok {
+		t.Fatalf("last resync missing from stats")
+	}
+	if rs.NodeID != s1.ID() || rs.Error != "" || rs.Index == 0 || rs.Time.IsZero() {
+		t.Fatalf("unexpected last resync: %+v", rs)
+	}
+}
+
 func Test_MultiNodeExecuteQueryFreshness(t *testing.T) {
 	s0, ln0 := mustNewStore(t, true)
 	defer ln0.Close()
@@ -2299,9 +2546,15 @@ func Test_SingleNodeSnapshotInMem(t *testing.T) {
 		t.Fatalf("failed to open snapshot file: %s", err.Error())
 	}
 	defer snapFile.Close()
+	if s.AppliedIndex() == 0 {
+		t.Fatalf("expected non-zero applied index before restore")
+	}
 	if err := s.Restore(snapFile); err != nil {
 		t.Fatalf("failed to restore snapshot from disk: %s", err.Error())
 	}
+	if exp, got := uint64(0), s.AppliedIndex(); exp != got {
+		t.Fatalf("expected applied index %d after restore, got %d", exp, got)
+	}
 
 	// Ensure database is back in the correct state.
 	r, err := s.Query(queryRequestFromString("SELECT * FROM foo", false, false))
@@ -2331,6 +2584,9 @@ func Test_SingleNodeSnapshotInMem(t *testing.T) {
 	if exp, got := `[[2]]`, asJSON(r[0].Values); exp != got {
 		t.Fatalf("unexpected results for query\nexp: %s\ngot: %s", exp, got)
 	}
+	if s.AppliedIndex() == 0 {
+		t.Fatalf("expected non-zero applied index after write following restore")
+	}
 }
 
 func Test_SingleNodeNoop(t *testing.T) {
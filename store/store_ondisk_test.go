@@ -188,6 +188,86 @@ COMMIT;
 	}
 }
 
+func Test_SingleNodeOnDiskBackupOffline(t *testing.T) {
+	s, ln := mustNewStore(t, false)
+	defer ln.Close()
+	s.SnapshotThreshold = 4
+	s.SnapshotInterval = 100 * time.Millisecond
+
+	if err := s.Open(); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	if err := s.Bootstrap(NewServer(s.ID(), s.Addr(), true)); err != nil {
+		t.Fatalf("failed to bootstrap single-node store: %s", err.Error())
+	}
+	if _, err := s.WaitForLeader(10 * time.Second); err != nil {
+		t.Fatalf("Error waiting for leader: %s", err)
+	}
+
+	_, err := s.Execute(executeRequestFromString(`CREATE TABLE foo (id INTEGER NOT NULL PRIMARY KEY, name TEXT)`, false, false))
+	if err != nil {
+		t.Fatalf("failed to execute on single node: %s", err.Error())
+	}
+	for i := 0; i < 9; i++ {
+		_, err := s.Execute(executeRequestFromString(`INSERT INTO foo(name) VALUES("fiona")`, false, false))
+		if err != nil {
+			t.Fatalf("failed to execute on single node: %s", err.Error())
+		}
+	}
+
+	// Wait for a snapshot to take place, and then write one more record so
+	// the backup must come from both the snapshot and the log.
+	for {
+		time.Sleep(100 * time.Millisecond)
+		s.numSnapshotsMu.Lock()
+		ns := s.numSnapshots
+		s.numSnapshotsMu.Unlock()
+		if ns > 0 {
+			break
+		}
+	}
+	_, err = s.Execute(executeRequestFromString(`INSERT INTO foo(name) VALUES("fiona")`, false, false))
+	if err != nil {
+		t.Fatalf("failed to execute on single node: %s", err.Error())
+	}
+
+	// Backing up while the node is running should fail.
+	bakPath := filepath.Join(t.TempDir(), "bak.sqlite")
+	if _, err := BackupOffline(s.Path(), nil, bakPath); err == nil {
+		t.Fatalf("expected error backing up offline with store open")
+	}
+
+	if err := s.Close(true); err != nil {
+		t.Fatalf("failed to close single-node store: %s", err.Error())
+	}
+	idx, err := BackupOffline(s.Path(), nil, bakPath)
+	if err != nil {
+		t.Fatalf("failed to back up offline: %s", err.Error())
+	}
+	if idx == 0 {
+		t.Fatalf("expected non-zero index for offline backup")
+	}
+
+	bakDB, err := db.Open(bakPath, false, false)
+	if err != nil {
+		t.Fatalf("unable to open backup database, %s", err.Error())
+	}
+	defer bakDB.Close()
+	r, err := bakDB.QueryStringStmt("SELECT COUNT(*) FROM foo")
+	if err != nil {
+		t.Fatalf("failed to query backup database: %s", err.Error())
+	}
+	if exp, got := `[[10]]`, asJSON(r[0].Values); exp != got {
+		t.Fatalf("unexpected results for query\nexp: %s\ngot: %s", exp, got)
+	}
+}
+
+func Test_BackupOfflineNoData(t *testing.T) {
+	if _, err := BackupOffline(t.TempDir(), nil, filepath.Join(t.TempDir(), "bak.sqlite")); err == nil {
+		t.Fatalf("expected error backing up empty data directory")
+	}
+}
+
 func Test_SingleNodeOnDiskRestoreNoncompressed(t *testing.T) {
 	s, ln := mustNewStore(t, false)
 	defer ln.Close()
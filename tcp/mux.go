@@ -98,16 +98,23 @@ func NewMux(ln net.Listener, adv net.Addr) (*Mux, error) {
 // then the server will not verify the client's certificate. If mutual is true,
 // then the server will require the client to present a trusted certificate.
 func NewTLSMux(ln net.Listener, adv net.Addr, cert, key, caCert string, insecure, mutual bool) (*Mux, error) {
-	mux, err := NewMux(ln, adv)
+	tlsConfig, err := rtls.CreateConfig(cert, key, caCert, insecure, mutual)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("cannot create TLS config: %s", err)
 	}
+	return NewTLSMuxWithConfig(ln, adv, tlsConfig)
+}
 
-	mux.tlsConfig, err = rtls.CreateConfig(cert, key, caCert, insecure, mutual)
+// NewTLSMuxWithConfig returns a new instance of Mux for ln, and encrypts all
+// traffic using the given TLS configuration. If adv is nil, then the addr of
+// ln is used.
+func NewTLSMuxWithConfig(ln net.Listener, adv net.Addr, tlsConfig *tls.Config) (*Mux, error) {
+	mux, err := NewMux(ln, adv)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create TLS config: %s", err)
+		return nil, err
 	}
 
+	mux.tlsConfig = tlsConfig
 	mux.ln = tls.NewListener(ln, mux.tlsConfig)
 
 	return mux, nil